@@ -0,0 +1,91 @@
+// Package builtins holds the functions, written in Go, that every Karma
+// program can call without defining them.
+//
+// Builtins live in a registry keyed by name. The evaluator looks a name up
+// here when no binding in scope has it, so a program can shadow a builtin
+// by binding the same name. Each builtin checks the number and types of its
+// arguments and reports mistakes as runtime errors worded the same way:
+//
+//	wrong number of arguments to `len`: want=1, got=2
+//	argument to `len` must be STRING, ARRAY or HASH, got INTEGER
+package builtins
+
+import (
+	"fmt"
+	"karma/object"
+	"sort"
+	"strings"
+)
+
+var registry = map[string]*object.Builtin{}
+
+// Register makes fn callable from Karma programs as name. It panics if
+// name is already registered.
+func Register(name string, fn object.BuiltinFunction) {
+	if _, dup := registry[name]; dup {
+		panic("builtins: Register called twice for " + name)
+	}
+	registry[name] = &object.Builtin{Name: name, Fn: fn}
+}
+
+// Lookup returns the builtin registered as name.
+func Lookup(name string) (*object.Builtin, bool) {
+	builtin, ok := registry[name]
+	return builtin, ok
+}
+
+// Names returns the names of all builtins, in sorted order.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkCount returns an error unless the builtin name was called with
+// between min and max arguments.
+func checkCount(name string, args []object.Object, min, max int) *object.Error {
+	got := len(args)
+	if got >= min && got <= max {
+		return nil
+	}
+
+	want := fmt.Sprint(min)
+	if max == min+1 {
+		want = fmt.Sprintf("%d or %d", min, max)
+	} else if max > min {
+		want = fmt.Sprintf("%d to %d", min, max)
+	}
+	return object.NewError("wrong number of arguments to `%s`: want=%s, got=%d", name, want, got)
+}
+
+// checkType returns an error unless args[i], an argument to the builtin
+// name, has one of the given types. Arguments are numbered from 1 in the
+// message when there is more than one.
+func checkType(name string, args []object.Object, i int, types ...object.ObjectType) *object.Error {
+	for _, t := range types {
+		if args[i].Type() == t {
+			return nil
+		}
+	}
+
+	which := "argument"
+	if len(args) > 1 {
+		which = fmt.Sprintf("argument %d", i+1)
+	}
+	return object.NewError("%s to `%s` must be %s, got %s", which, name, typeList(types), args[i].Type())
+}
+
+// typeList joins types as in "STRING, ARRAY or HASH".
+func typeList(types []object.ObjectType) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = string(t)
+	}
+	if len(names) == 1 {
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
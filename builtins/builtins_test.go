@@ -0,0 +1,111 @@
+package builtins
+
+import (
+	"bytes"
+	"karma/object"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	for _, name := range []string{"args", "env", "exit", "len", "print", "push", "first", "rest", "keys", "type", "str", "int"} {
+		builtin, ok := Lookup(name)
+		if !ok {
+			t.Errorf("builtin %s is not registered", name)
+			continue
+		}
+		if builtin.Name != name {
+			t.Errorf("builtin %s has wrong name %q", name, builtin.Name)
+		}
+	}
+
+	if _, ok := Lookup("nope"); ok {
+		t.Errorf("Lookup found an unregistered builtin")
+	}
+
+	names := Names()
+	if !sortedStrings(names) || len(names) != len(registry) {
+		t.Errorf("Names() is not the sorted list of builtins. got=%q", names)
+	}
+}
+
+func sortedStrings(s []string) bool {
+	for i := 1; i < len(s); i++ {
+		if s[i-1] >= s[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRegister(t *testing.T) {
+	Register("test_answer", func(config *object.Config, args ...object.Object) object.Object {
+		return &object.Integer{Value: 42}
+	})
+	defer delete(registry, "test_answer")
+
+	builtin, ok := Lookup("test_answer")
+	if !ok {
+		t.Fatalf("registered builtin not found")
+	}
+	if got := builtin.Fn(&object.Config{}).Inspect(); got != "42" {
+		t.Errorf("wrong result. want=42, got=%s", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("registering a name twice did not panic")
+		}
+	}()
+	Register("test_answer", builtin.Fn)
+}
+
+func TestArgumentChecks(t *testing.T) {
+	one := &object.Integer{Value: 1}
+
+	tests := []struct {
+		err      *object.Error
+		expected string
+	}{
+		{checkCount("f", nil, 1, 1), "wrong number of arguments to `f`: want=1, got=0"},
+		{checkCount("f", nil, 1, 2), "wrong number of arguments to `f`: want=1 or 2, got=0"},
+		{checkCount("f", []object.Object{one, one, one, one}, 1, 3), "wrong number of arguments to `f`: want=1 to 3, got=4"},
+		{checkType("f", []object.Object{one}, 0, object.STRING_OBJ), "argument to `f` must be STRING, got INTEGER"},
+		{checkType("f", []object.Object{one, one}, 1, object.STRING_OBJ, object.ARRAY_OBJ), "argument 2 to `f` must be STRING or ARRAY, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		if tt.err == nil {
+			t.Errorf("no error returned, want %q", tt.expected)
+			continue
+		}
+		if tt.err.Message != tt.expected {
+			t.Errorf("wrong message. want=%q, got=%q", tt.expected, tt.err.Message)
+		}
+	}
+
+	if err := checkCount("f", []object.Object{one}, 0, 1); err != nil {
+		t.Errorf("unexpected error %q", err.Message)
+	}
+	if err := checkType("f", []object.Object{one}, 0, object.STRING_OBJ, object.INTEGER_OBJ); err != nil {
+		t.Errorf("unexpected error %q", err.Message)
+	}
+}
+
+func TestPrintOutput(t *testing.T) {
+	var out, other bytes.Buffer
+	config := &object.Config{Output: &out}
+
+	result := builtinPrint(config, &object.String{Value: "a b"}, &object.Integer{Value: 1}, &object.Array{})
+	builtinPrint(&object.Config{Output: &other}, &object.String{Value: "elsewhere"})
+	builtinPrint(config)
+
+	if result != object.NULL {
+		t.Errorf("print returned %s, want null", result.Inspect())
+	}
+	if expected := "a b 1 []\n\n"; out.String() != expected {
+		t.Errorf("wrong output. want=%q, got=%q", expected, out.String())
+	}
+	if expected := "elsewhere\n"; other.String() != expected {
+		t.Errorf("print wrote to the wrong output. want=%q, got=%q", expected, other.String())
+	}
+}
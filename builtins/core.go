@@ -0,0 +1,176 @@
+package builtins
+
+import (
+	"fmt"
+	"karma/object"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The basic builtins for strings, arrays, hashes, printing and converting
+// between types.
+func init() {
+	Register("len", builtinLen)
+	Register("print", builtinPrint)
+	Register("push", builtinPush)
+	Register("first", builtinFirst)
+	Register("rest", builtinRest)
+	Register("keys", builtinKeys)
+	Register("type", builtinType)
+	Register("str", builtinStr)
+	Register("int", builtinInt)
+}
+
+// builtinLen implements len(x), the number of characters in a string, of
+// elements in an array or of pairs in a hash.
+func builtinLen(config *object.Config, args ...object.Object) object.Object {
+	if err := checkCount("len", args, 1, 1); err != nil {
+		return err
+	}
+	if err := checkType("len", args, 0, object.STRING_OBJ, object.ARRAY_OBJ, object.HASH_OBJ); err != nil {
+		return err
+	}
+
+	switch arg := args[0].(type) {
+	case *object.String:
+		return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+	case *object.Array:
+		return &object.Integer{Value: int64(len(arg.Elements))}
+	default:
+		return &object.Integer{Value: int64(arg.(*object.Hash).Len())}
+	}
+}
+
+// builtinPrint implements print(a, b, ...), which writes its arguments to
+// the configured output, separated by spaces and followed by a newline.
+// Strings are written as their text, not quoted.
+func builtinPrint(config *object.Config, args ...object.Object) object.Object {
+	texts := make([]string, len(args))
+	for i, arg := range args {
+		texts[i] = object.Text(arg)
+	}
+	fmt.Fprintln(config.Output, strings.Join(texts, " "))
+	return object.NULL
+}
+
+// builtinPush implements push(xs, x), which returns a new array holding
+// the elements of xs followed by x. xs itself is left unchanged.
+func builtinPush(config *object.Config, args ...object.Object) object.Object {
+	if err := checkCount("push", args, 2, 2); err != nil {
+		return err
+	}
+	if err := checkType("push", args, 0, object.ARRAY_OBJ); err != nil {
+		return err
+	}
+
+	elements := args[0].(*object.Array).Elements
+	pushed := make([]object.Object, len(elements), len(elements)+1)
+	copy(pushed, elements)
+	return &object.Array{Elements: append(pushed, args[1])}
+}
+
+// builtinFirst implements first(xs), the first element of an array, or
+// null when it is empty.
+func builtinFirst(config *object.Config, args ...object.Object) object.Object {
+	if err := checkCount("first", args, 1, 1); err != nil {
+		return err
+	}
+	if err := checkType("first", args, 0, object.ARRAY_OBJ); err != nil {
+		return err
+	}
+
+	elements := args[0].(*object.Array).Elements
+	if len(elements) == 0 {
+		return object.NULL
+	}
+	return elements[0]
+}
+
+// builtinRest implements rest(xs), a new array holding every element of
+// xs but the first, or null when xs is empty.
+func builtinRest(config *object.Config, args ...object.Object) object.Object {
+	if err := checkCount("rest", args, 1, 1); err != nil {
+		return err
+	}
+	if err := checkType("rest", args, 0, object.ARRAY_OBJ); err != nil {
+		return err
+	}
+
+	elements := args[0].(*object.Array).Elements
+	if len(elements) == 0 {
+		return object.NULL
+	}
+	rest := make([]object.Object, len(elements)-1)
+	copy(rest, elements[1:])
+	return &object.Array{Elements: rest}
+}
+
+// builtinKeys implements keys(h), an array of the keys of a hash in the
+// order they were added.
+func builtinKeys(config *object.Config, args ...object.Object) object.Object {
+	if err := checkCount("keys", args, 1, 1); err != nil {
+		return err
+	}
+	if err := checkType("keys", args, 0, object.HASH_OBJ); err != nil {
+		return err
+	}
+
+	pairs := args[0].(*object.Hash).Pairs()
+	keys := make([]object.Object, len(pairs))
+	for i, pair := range pairs {
+		keys[i] = pair.Key
+	}
+	return &object.Array{Elements: keys}
+}
+
+// builtinType implements type(x), the name of the type of x, such as
+// "INTEGER".
+func builtinType(config *object.Config, args ...object.Object) object.Object {
+	if err := checkCount("type", args, 1, 1); err != nil {
+		return err
+	}
+	return &object.String{Value: string(args[0].Type())}
+}
+
+// builtinStr implements str(x), the text x contributes to a string: a
+// string is returned as it is, any other value in its printed form.
+func builtinStr(config *object.Config, args ...object.Object) object.Object {
+	if err := checkCount("str", args, 1, 1); err != nil {
+		return err
+	}
+	return &object.String{Value: object.Text(args[0])}
+}
+
+// builtinInt implements int(x), which converts a string of decimal digits,
+// a float, truncating it toward zero, or a boolean to an integer.
+func builtinInt(config *object.Config, args ...object.Object) object.Object {
+	if err := checkCount("int", args, 1, 1); err != nil {
+		return err
+	}
+	if err := checkType("int", args, 0, object.INTEGER_OBJ, object.FLOAT_OBJ, object.STRING_OBJ, object.BOOLEAN_OBJ); err != nil {
+		return err
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		return arg
+	case *object.Float:
+		if math.IsNaN(arg.Value) || arg.Value >= math.MaxInt64 || arg.Value < math.MinInt64 {
+			return object.NewError("cannot convert %s to INTEGER", arg.Inspect())
+		}
+		return &object.Integer{Value: int64(arg.Value)}
+	case *object.String:
+		value, err := strconv.ParseInt(arg.Value, 10, 64)
+		if err != nil {
+			return object.NewError("cannot convert %s to INTEGER", arg.Inspect())
+		}
+		return &object.Integer{Value: value}
+	default:
+		if arg.(*object.Boolean).Value {
+			return &object.Integer{Value: 1}
+		}
+		return &object.Integer{Value: 0}
+	}
+}
@@ -0,0 +1,63 @@
+package builtins
+
+import (
+	"karma/object"
+	"os"
+)
+
+// The builtins scripts use to talk to the world around them.
+func init() {
+	Register("args", builtinArgs)
+	Register("env", builtinEnv)
+	Register("exit", builtinExit)
+}
+
+// builtinArgs implements args(), which returns the arguments given to the
+// script as an array of strings.
+func builtinArgs(config *object.Config, args ...object.Object) object.Object {
+	if err := checkCount("args", args, 0, 0); err != nil {
+		return err
+	}
+
+	elements := make([]object.Object, len(config.Args))
+	for i, arg := range config.Args {
+		elements[i] = &object.String{Value: arg}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// builtinEnv implements env(name), which returns the value of the named
+// environment variable, or null when it is not set.
+func builtinEnv(config *object.Config, args ...object.Object) object.Object {
+	if err := checkCount("env", args, 1, 1); err != nil {
+		return err
+	}
+	if err := checkType("env", args, 0, object.STRING_OBJ); err != nil {
+		return err
+	}
+
+	if val, ok := os.LookupEnv(args[0].(*object.String).Value); ok {
+		return &object.String{Value: val}
+	}
+	return object.NULL
+}
+
+// builtinExit implements exit() and exit(code), which end the program
+// with the given status, 0 by default.
+func builtinExit(config *object.Config, args ...object.Object) object.Object {
+	if err := checkCount("exit", args, 0, 1); err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return &object.Exit{Code: 0}
+	}
+
+	if err := checkType("exit", args, 0, object.INTEGER_OBJ); err != nil {
+		return err
+	}
+	code := args[0].(*object.Integer).Value
+	if code < 0 || code > 255 {
+		return object.NewError("exit code out of range: %d; want 0 to 255", code)
+	}
+	return &object.Exit{Code: int(code)}
+}
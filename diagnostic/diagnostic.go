@@ -0,0 +1,98 @@
+// Package diagnostic describes problems found in Karma source code and
+// renders them for people to read.
+//
+// A Diagnostic says what is wrong (Message), how serious it is (Severity),
+// which kind of problem it is (Code) and where (Span). Secondary Labels
+// point at related places, such as where an unclosed bracket was opened,
+// and Notes suggest how to fix the problem.
+//
+// Render prints a diagnostic together with the source line it refers to,
+// underlining the span:
+//
+//	main.ka:1:3: error[E0205]: cannot assign to 5
+//	 1 | 5 = x;
+//	   |   ^
+//	   = note: only a name can be assigned to, as in x = 1
+package diagnostic
+
+import (
+	"fmt"
+	"karma/token"
+)
+
+// Severity says how serious a diagnostic is.
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Note
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	case Note:
+		return "note"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// Span is a range of source text, from the first character up to the
+// character just past the end.
+type Span struct {
+	Start, End token.Position
+}
+
+// TokenSpan returns the span of tok.
+func TokenSpan(tok token.Token) Span {
+	return Span{Start: tok.Start, End: tok.End}
+}
+
+// Label attaches a message to a span related to a diagnostic.
+type Label struct {
+	Span    Span
+	Message string
+}
+
+// Diagnostic is a problem found in source code.
+type Diagnostic struct {
+	Severity Severity
+	Code     string // identifies the kind of problem, such as "E0201"
+	Message  string
+	Span     Span // where the problem is
+	Labels   []Label
+	Notes    []string
+}
+
+// Errorf returns an error diagnostic at span with a formatted message.
+func Errorf(span Span, code, format string, a ...interface{}) Diagnostic {
+	return Diagnostic{
+		Severity: Error,
+		Code:     code,
+		Message:  fmt.Sprintf(format, a...),
+		Span:     span,
+	}
+}
+
+// WithLabel returns d with a label attaching message to span.
+func (d Diagnostic) WithLabel(span Span, message string) Diagnostic {
+	d.Labels = append(d.Labels[:len(d.Labels):len(d.Labels)], Label{Span: span, Message: message})
+	return d
+}
+
+// WithNote returns d with a note added.
+func (d Diagnostic) WithNote(note string) Diagnostic {
+	d.Notes = append(d.Notes[:len(d.Notes):len(d.Notes)], note)
+	return d
+}
+
+// Error formats the diagnostic on one line as "pos: message", the form
+// parser errors have always had.
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("%s: %s", d.Span.Start, d.Message)
+}
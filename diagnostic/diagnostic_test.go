@@ -0,0 +1,129 @@
+package diagnostic
+
+import (
+	"bytes"
+	"karma/token"
+	"strings"
+	"testing"
+)
+
+func pos(line, column int) token.Position {
+	return token.Position{Filename: "main.ka", Line: line, Column: column}
+}
+
+func span(line, column, endColumn int) Span {
+	return Span{Start: pos(line, column), End: pos(line, endColumn)}
+}
+
+func TestRender(t *testing.T) {
+	src := "karma f = fun(x, x) {\n\tx +\n}\n"
+
+	tests := []struct {
+		d        Diagnostic
+		expected string
+	}{
+		{
+			Errorf(span(1, 18, 19), "E0206", "duplicate parameter %s", "x").
+				WithLabel(span(1, 15, 16), "first declared here"),
+			"main.ka:1:18: error[E0206]: duplicate parameter x\n" +
+				" 1 | karma f = fun(x, x) {\n" +
+				"   |               - first declared here\n" +
+				"   |                  ^\n",
+		},
+		{
+			// labels on other lines keep their own source line
+			Errorf(span(3, 1, 2), "E0202", "unclosed block").
+				WithLabel(span(1, 21, 22), "block opened here").
+				WithLabel(span(1, 11, 14), "in this function"),
+			"main.ka:3:1: error[E0202]: unclosed block\n" +
+				" 1 | karma f = fun(x, x) {\n" +
+				"   |           --- in this function\n" +
+				"   |                     - block opened here\n" +
+				" 3 | }\n" +
+				"   | ^\n",
+		},
+		{
+			Errorf(span(1, 11, 14), "E0200", "expected a name").WithNote("names start with a letter"),
+			"main.ka:1:11: error[E0200]: expected a name\n" +
+				" 1 | karma f = fun(x, x) {\n" +
+				"   |           ^~~\n" +
+				"   = note: names start with a letter\n",
+		},
+		{
+			// tabs are kept so that the underline lines up
+			Errorf(span(2, 4, 4), "", "expected an expression"),
+			"main.ka:2:4: error: expected an expression\n" +
+				" 2 | \tx +\n" +
+				"   | \t  ^\n",
+		},
+		{
+			// a span running onto the next line is underlined to the end of its first line
+			Errorf(Span{Start: pos(1, 21), End: pos(3, 2)}, "E0202", "unclosed block"),
+			"main.ka:1:21: error[E0202]: unclosed block\n" +
+				" 1 | karma f = fun(x, x) {\n" +
+				"   |                     ^\n",
+		},
+		{
+			// lines the source does not have are left out
+			Diagnostic{Severity: Warning, Message: "odd", Span: span(9, 1, 2)},
+			"main.ka:9:1: warning: odd\n",
+		},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		NewRenderer(&out).Render(tt.d, src)
+
+		if out.String() != tt.expected {
+			t.Errorf("wrong rendering.\nexpected=\n%s\ngot=\n%s", tt.expected, out.String())
+		}
+	}
+}
+
+func TestRenderGutterWidth(t *testing.T) {
+	src := strings.Repeat("\n", 11) + "x y"
+
+	var out bytes.Buffer
+	d := Errorf(span(12, 3, 4), "", "unexpected name").WithLabel(span(2, 1, 1), "")
+	NewRenderer(&out).Render(d, src)
+
+	expected := "main.ka:12:3: error: unexpected name\n" +
+		"  2 | \n" +
+		"    | -\n" +
+		" 12 | x y\n" +
+		"    |   ^\n"
+	if out.String() != expected {
+		t.Errorf("wrong rendering.\nexpected=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
+
+func TestRenderColor(t *testing.T) {
+	var out bytes.Buffer
+	r := NewRenderer(&out)
+	r.SetColor(true)
+	r.Render(Errorf(span(1, 1, 2), "E0201", "bad"), "x")
+
+	if !strings.Contains(out.String(), red+"error[E0201]"+reset) {
+		t.Errorf("severity is not coloured. got=%q", out.String())
+	}
+	if !strings.Contains(out.String(), red+"^"+reset) {
+		t.Errorf("underline is not coloured. got=%q", out.String())
+	}
+
+	out.Reset()
+	NewRenderer(&out).Render(Errorf(span(1, 1, 2), "E0201", "bad"), "x")
+	if strings.Contains(out.String(), "\x1b[") {
+		t.Errorf("output to a buffer is coloured. got=%q", out.String())
+	}
+}
+
+func TestError(t *testing.T) {
+	d := Errorf(span(3, 4, 5), "E0200", "expected %s", "';'").WithNote("ignored")
+
+	if got := d.Error(); got != "main.ka:3:4: expected ';'" {
+		t.Errorf("wrong Error(). got=%q", got)
+	}
+	if d.Severity.String() != "error" || Note.String() != "note" {
+		t.Errorf("wrong severity names. got=%s, %s", d.Severity, Note)
+	}
+}
@@ -0,0 +1,179 @@
+package diagnostic
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ANSI escape sequences used when colour is on.
+const (
+	bold   = "\x1b[1m"
+	red    = "\x1b[1;31m"
+	yellow = "\x1b[1;33m"
+	cyan   = "\x1b[1;36m"
+	blue   = "\x1b[1;34m"
+	reset  = "\x1b[0m"
+)
+
+// Renderer prints diagnostics along with the source they refer to.
+type Renderer struct {
+	w     io.Writer
+	color bool
+}
+
+// NewRenderer returns a Renderer writing to w. Output is coloured when w
+// is a terminal, unless the NO_COLOR environment variable is set.
+func NewRenderer(w io.Writer) *Renderer {
+	return &Renderer{w: w, color: isTerminal(w) && os.Getenv("NO_COLOR") == ""}
+}
+
+// SetColor turns colour on or off.
+func (r *Renderer) SetColor(on bool) {
+	r.color = on
+}
+
+// isTerminal reports whether w is a character device such as a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Render prints d, then the lines of src its span and labels point at,
+// each once, with the primary span underlined with ^~~~ and every label
+// with ----, and finally its notes. src is the whole text of the file d refers to;
+// lines it does not have are left out.
+func (r *Renderer) Render(d Diagnostic, src string) {
+	fmt.Fprintf(r.w, "%s%s:%s %s: %s%s%s\n",
+		r.paint(bold), d.Span.Start, r.paint(reset), r.severity(d), r.paint(bold), d.Message, r.paint(reset))
+
+	marks := []mark{{span: d.Span, primary: true}}
+	for _, l := range d.Labels {
+		marks = append(marks, mark{span: l.Span, message: l.Message})
+	}
+	sort.SliceStable(marks, func(i, j int) bool {
+		a, b := marks[i].span.Start, marks[j].span.Start
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+
+	lines := strings.Split(src, "\n")
+	width := len(fmt.Sprint(maxLine(marks)))
+	gutter := strings.Repeat(" ", width)
+
+	// each line is printed once, with the underlines of all its marks
+	for i, m := range marks {
+		n := m.span.Start.Line
+		if n < 1 || n > len(lines) {
+			continue
+		}
+		line := strings.TrimSuffix(lines[n-1], "\r")
+		if i == 0 || marks[i-1].span.Start.Line != n {
+			fmt.Fprintf(r.w, " %s%*d |%s %s\n", r.paint(blue), width, n, r.paint(reset), line)
+		}
+		fmt.Fprintf(r.w, " %s%s |%s %s\n", r.paint(blue), gutter, r.paint(reset), r.underline(m, line, d.Severity))
+	}
+
+	for _, note := range d.Notes {
+		fmt.Fprintf(r.w, " %s%s =%s %snote:%s %s\n", r.paint(blue), gutter, r.paint(reset), r.paint(bold), r.paint(reset), note)
+	}
+}
+
+// RenderAll renders each of ds in turn.
+func (r *Renderer) RenderAll(ds []Diagnostic, src string) {
+	for _, d := range ds {
+		r.Render(d, src)
+	}
+}
+
+// mark is a span to underline, with the message of its label.
+type mark struct {
+	span    Span
+	message string
+	primary bool
+}
+
+func maxLine(marks []mark) int {
+	max := 1
+	for _, m := range marks {
+		if m.span.Start.Line > max {
+			max = m.span.Start.Line
+		}
+	}
+	return max
+}
+
+// underline returns the line drawn under line to mark m: spaces up to the
+// start of the span, keeping tabs so the marks line up, then the marks.
+// A span running past the end of the line is underlined to its end; an
+// empty span, such as the end of input, still gets one mark.
+func (r *Renderer) underline(m mark, line string, severity Severity) string {
+	var out strings.Builder
+
+	col := 1
+	for _, ch := range line {
+		if col >= m.span.Start.Column {
+			break
+		}
+		if ch == '\t' {
+			out.WriteByte('\t')
+		} else {
+			out.WriteByte(' ')
+		}
+		col++
+	}
+
+	length := 1
+	if m.span.End.Line == m.span.Start.Line && m.span.End.Column > m.span.Start.Column {
+		length = m.span.End.Column - m.span.Start.Column
+	} else if rest := utf8.RuneCountInString(line) - m.span.Start.Column + 1; m.span.End.Line > m.span.Start.Line && rest > 1 {
+		length = rest
+	}
+
+	if m.primary {
+		out.WriteString(r.severityColor(severity))
+		out.WriteString("^" + strings.Repeat("~", length-1))
+	} else {
+		out.WriteString(r.paint(blue))
+		out.WriteString(strings.Repeat("-", length))
+	}
+	if m.message != "" {
+		out.WriteString(" " + m.message)
+	}
+	out.WriteString(r.paint(reset))
+	return out.String()
+}
+
+// severity returns the severity and code of d, as in "error[E0201]".
+func (r *Renderer) severity(d Diagnostic) string {
+	text := d.Severity.String()
+	if d.Code != "" {
+		text += "[" + d.Code + "]"
+	}
+	return r.severityColor(d.Severity) + text + r.paint(reset)
+}
+
+func (r *Renderer) severityColor(s Severity) string {
+	switch s {
+	case Error:
+		return r.paint(red)
+	case Warning:
+		return r.paint(yellow)
+	default:
+		return r.paint(cyan)
+	}
+}
+
+// paint returns the escape sequence code when colour is on.
+func (r *Renderer) paint(code string) string {
+	if !r.color {
+		return ""
+	}
+	return code
+}
@@ -0,0 +1,776 @@
+// Package evaluator runs Karma programs by walking their abstract syntax tree.
+//
+// Eval takes any ast.Node and an environment holding the bindings in scope,
+// and returns the object.Object the node evaluates to. Runtime errors are
+// values too (*object.Error): once produced they stop the evaluation of the
+// enclosing statements and become the result of the program. A call to the
+// exit builtin (*object.Exit) unwinds the same way.
+package evaluator
+
+import (
+	"karma/ast"
+	"karma/builtins"
+	"karma/object"
+	"math"
+	"strings"
+)
+
+// The values of true, false and null never change, so they are shared
+// with the builtins.
+var (
+	NULL  = object.NULL
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
+
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
+)
+
+// Eval evaluates node in env and returns its value.
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+
+	// Statements
+	case *ast.Program:
+		return evalProgram(node, env)
+
+	case *ast.BlockStatement:
+		return evalBlockStatement(node, env)
+
+	case *ast.ExpressionStatement:
+		return Eval(node.Expression, env)
+
+	case *ast.LetStatement:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		env.Set(node.Name.Value, val)
+		return nil
+
+	case *ast.ReturnStatement:
+		if node.ReturnValue == nil {
+			return &object.ReturnValue{Value: NULL}
+		}
+		val := Eval(node.ReturnValue, env)
+		if isError(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
+
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+
+	case *ast.BreakStatement:
+		return BREAK
+
+	case *ast.ContinueStatement:
+		return CONTINUE
+
+	// Expressions
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: node.Value}
+
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
+
+	case *ast.Boolean:
+		return nativeBoolToBooleanObject(node.Value)
+
+	case *ast.ConcatExpression:
+		return evalConcatExpression(node, env)
+
+	case *ast.Identifier:
+		return evalIdentifier(node, env)
+
+	case *ast.PrefixExpression:
+		right := Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return evalPrefixExpression(node.Operator, right)
+
+	case *ast.InfixExpression:
+		switch node.Operator {
+		case "&&", "||":
+			return evalLogicalExpression(node, env)
+		case "|>":
+			return evalPipeExpression(node, env)
+		}
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		right := Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return evalInfixExpression(node.Operator, left, right)
+
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
+
+	case *ast.IfExpression:
+		return evalIfExpression(node, env)
+
+	case *ast.FunctionLiteral:
+		return &object.Function{Parameters: node.Parameters, Body: node.Body, Env: env}
+
+	case *ast.CallExpression:
+		function := Eval(node.Function, env)
+		if isError(function) {
+			return function
+		}
+		args := evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+		return applyFunction(function, args, env)
+
+	case *ast.ArrayLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
+
+	case *ast.IndexExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(node.Index, env)
+		if isError(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
+
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
+	}
+
+	return newError("cannot evaluate %s", node.String())
+}
+
+// evalProgram evaluates the statements of a program in order. A return
+// statement, an error or a call to exit ends the program early.
+func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+	var result object.Object
+
+	for _, statement := range program.Statements {
+		result = Eval(statement, env)
+
+		switch result := result.(type) {
+		case *object.ReturnValue:
+			return result.Value
+		case *object.Error, *object.Exit:
+			return result
+		}
+	}
+
+	return result
+}
+
+// evalBlockStatement evaluates the statements of a block in order. Unlike
+// evalProgram it leaves return values wrapped, so that a return inside a
+// nested block still unwinds all the way out of the enclosing function;
+// break and continue unwind the same way, out to the enclosing loop. A
+// block that produces no value evaluates to null.
+func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object = NULL
+
+	for _, statement := range block.Statements {
+		result = Eval(statement, env)
+
+		if result == nil {
+			result = NULL
+			continue
+		}
+		switch result.Type() {
+		case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.EXIT_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+			return result
+		}
+	}
+
+	return result
+}
+
+// evalWhileStatement runs the body of a while loop for as long as its
+// condition is truthy.
+func evalWhileStatement(node *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(node.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return nil
+		}
+
+		if result, done := evalLoopBody(node.Body, env); done {
+			return result
+		}
+	}
+}
+
+// evalForStatement runs the body of a for loop once for every item of its
+// iterable: the elements of an array, the keys of a hash in order, the
+// characters of a string or the integers of a range. Every iteration gets
+// its own environment binding the loop variable, so a function made in
+// the body keeps the item of its own iteration.
+func evalForStatement(node *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := Eval(node.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	var result object.Object
+	run := func(item object.Object) bool {
+		loopEnv := object.NewEnclosedEnvironment(env)
+		loopEnv.Set(node.Variable.Value, item)
+
+		var done bool
+		result, done = evalLoopBody(node.Body, loopEnv)
+		return !done
+	}
+
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		for _, el := range iterable.Elements {
+			if !run(el) {
+				break
+			}
+		}
+	case *object.Hash:
+		for _, pair := range iterable.Pairs() {
+			if !run(pair.Key) {
+				break
+			}
+		}
+	case *object.String:
+		for _, ch := range iterable.Value {
+			if !run(&object.String{Value: string(ch)}) {
+				break
+			}
+		}
+	case *object.Range:
+		for i := iterable.Start; i < iterable.End; i++ {
+			if !run(&object.Integer{Value: i}) {
+				break
+			}
+		}
+	default:
+		return newError("cannot iterate over %s", iterable.Type())
+	}
+
+	return result
+}
+
+// evalLoopBody runs the body of a loop once, and reports whether the loop
+// is done: after a break, or when a return, an error or a call to exit
+// unwinds past the loop. In that last case result is what unwinds.
+func evalLoopBody(body *ast.BlockStatement, env *object.Environment) (result object.Object, done bool) {
+	switch result := Eval(body, env).(type) {
+	case *object.Break:
+		return nil, true
+	case *object.ReturnValue, *object.Error, *object.Exit:
+		return result, true
+	}
+	return nil, false
+}
+
+func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if val, ok := env.Get(node.Value); ok {
+		return val
+	}
+	if builtin, ok := builtins.Lookup(node.Value); ok {
+		return builtin
+	}
+	return newError("identifier not found: %s", node.Value)
+}
+
+// evalConcatExpression joins the string forms of the parts of an
+// interpolated string. Strings contribute their text, every other value
+// its Inspect form.
+func evalConcatExpression(node *ast.ConcatExpression, env *object.Environment) object.Object {
+	var out strings.Builder
+
+	for _, part := range node.Parts {
+		val := Eval(part, env)
+		if isError(val) {
+			return val
+		}
+		out.WriteString(object.Text(val))
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+func evalPrefixExpression(operator string, right object.Object) object.Object {
+	switch operator {
+	case "!":
+		return evalBangOperatorExpression(right)
+	case "-":
+		return evalMinusPrefixOperatorExpression(right)
+	default:
+		return newError("unknown operator: %s%s", operator, right.Type())
+	}
+}
+
+func evalBangOperatorExpression(right object.Object) object.Object {
+	return nativeBoolToBooleanObject(!isTruthy(right))
+}
+
+func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newError("unknown operator: -%s", right.Type())
+	}
+}
+
+// evalLogicalExpression evaluates && and ||. The right operand is only
+// evaluated when the left one does not decide the result.
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	if node.Operator == "&&" && !isTruthy(left) {
+		return FALSE
+	}
+	if node.Operator == "||" && isTruthy(left) {
+		return TRUE
+	}
+
+	right := Eval(node.Right, env)
+	if isError(right) {
+		return right
+	}
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
+func evalInfixExpression(operator string, left, right object.Object) object.Object {
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, toFloat(left), toFloat(right))
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(operator, left, right)
+	case left.Type() != right.Type():
+		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	case operator == "==":
+		return nativeBoolToBooleanObject(left == right)
+	case operator == "!=":
+		return nativeBoolToBooleanObject(left != right)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.Integer).Value
+	rightVal := right.(*object.Integer).Value
+
+	switch operator {
+	case "+":
+		return &object.Integer{Value: leftVal + rightVal}
+	case "-":
+		return &object.Integer{Value: leftVal - rightVal}
+	case "*":
+		return &object.Integer{Value: leftVal * rightVal}
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero: %d / 0", leftVal)
+		}
+		return &object.Integer{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero: %d %% 0", leftVal)
+		}
+		return &object.Integer{Value: leftVal % rightVal}
+	case "..":
+		return &object.Range{Start: leftVal, End: rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalFloatInfixExpression evaluates arithmetic and comparisons where at
+// least one operand is a float; integers are converted to floats first.
+func evalFloatInfixExpression(operator string, leftVal, rightVal float64) object.Object {
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", object.FLOAT_OBJ, operator, object.FLOAT_OBJ)
+	}
+}
+
+func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalIndexExpression returns the element of an array at index, or the
+// value of a hash at the key index.
+func evalIndexExpression(left, index object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		return evalArrayIndexExpression(left, index)
+	case *object.Hash:
+		return evalHashIndexExpression(left, index)
+	default:
+		return newError("index operator not supported: %s", left.Type())
+	}
+}
+
+// evalArrayIndexExpression returns the element of array at index. A
+// negative index counts from the end, so xs[-1] is the last element.
+func evalArrayIndexExpression(array *object.Array, index object.Object) object.Object {
+	i, ok := index.(*object.Integer)
+	if !ok {
+		return newError("index must be INTEGER, got %s", index.Type())
+	}
+
+	length := int64(len(array.Elements))
+	idx := i.Value
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 || idx >= length {
+		return newError("index out of range: %d with length %d", i.Value, length)
+	}
+	return array.Elements[idx]
+}
+
+// evalHashIndexExpression returns the value of hash at key, or null when
+// there is none.
+func evalHashIndexExpression(hash *object.Hash, key object.Object) object.Object {
+	hashable, ok := key.(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", key.Type())
+	}
+	if value, ok := hash.Get(hashable); ok {
+		return value
+	}
+	return NULL
+}
+
+// evalHashLiteral evaluates the pairs of a hash literal in order. When a
+// key appears twice, the later value wins.
+func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
+		if isError(key) {
+			return key
+		}
+		hashable, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+
+		value := Eval(pair.Value, env)
+		if isError(value) {
+			return value
+		}
+		hash.Set(hashable, value)
+	}
+
+	return hash
+}
+
+// evalSliceExpression returns a new array holding the elements of an array
+// from the low bound up to, but not including, the high bound. A missing
+// low bound means 0 and a missing high bound the length; negative bounds
+// count from the end.
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	array, ok := left.(*object.Array)
+	if !ok {
+		return newError("slice operator not supported: %s", left.Type())
+	}
+
+	length := int64(len(array.Elements))
+	low, err := evalSliceBound(node.Low, 0, length, env)
+	if err != nil {
+		return err
+	}
+	high, err := evalSliceBound(node.High, length, length, env)
+	if err != nil {
+		return err
+	}
+
+	if low < 0 || high > length || low > high {
+		return newError("slice bounds out of range: %d:%d with length %d", low, high, length)
+	}
+
+	elements := make([]object.Object, high-low)
+	copy(elements, array.Elements[low:high])
+	return &object.Array{Elements: elements}
+}
+
+// evalSliceBound evaluates a bound of a slice of something length long,
+// which is def when it is left out. A negative bound counts from the end.
+func evalSliceBound(exp ast.Expression, def, length int64, env *object.Environment) (int64, object.Object) {
+	if exp == nil {
+		return def, nil
+	}
+	obj := Eval(exp, env)
+	if isError(obj) {
+		return 0, obj
+	}
+	i, ok := obj.(*object.Integer)
+	if !ok {
+		return 0, newError("slice bound must be INTEGER, got %s", obj.Type())
+	}
+	if i.Value < 0 {
+		return i.Value + length, nil
+	}
+	return i.Value, nil
+}
+
+// evalAssignExpression rebinds an existing name. A compound assignment
+// such as x += 1 is evaluated as x = x + 1. The value of the expression is
+// the new value.
+func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	name := node.Name.Value
+
+	current, ok := env.Get(name)
+	if !ok {
+		return newError("cannot assign to undefined variable %s; declare it with karma %s = ...", name, name)
+	}
+
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	if node.Operator != "=" {
+		val = evalInfixExpression(strings.TrimSuffix(node.Operator, "="), current, val)
+		if isError(val) {
+			return val
+		}
+	}
+
+	env.Assign(name, val)
+	return val
+}
+
+func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := Eval(ie.Condition, env)
+	if isError(condition) {
+		return condition
+	}
+
+	if isTruthy(condition) {
+		return Eval(ie.Consequence, env)
+	} else if ie.Alternative != nil {
+		return Eval(ie.Alternative, env)
+	} else {
+		return NULL
+	}
+}
+
+// evalExpressions evaluates exps from left to right. If one of them fails,
+// or breaks out of a loop, it returns just that value.
+func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+	var result []object.Object
+
+	for _, e := range exps {
+		evaluated := Eval(e, env)
+		if isError(evaluated) {
+			return []object.Object{evaluated}
+		}
+		result = append(result, evaluated)
+	}
+
+	return result
+}
+
+// evalPipeExpression evaluates x |> f as f(x). When the right-hand side is
+// a call, the piped value becomes its first argument: x |> f(y) is f(x, y).
+func evalPipeExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	callee, rest := node.Right, []ast.Expression(nil)
+	if call, ok := node.Right.(*ast.CallExpression); ok {
+		callee, rest = call.Function, call.Arguments
+	}
+
+	function := Eval(callee, env)
+	if isError(function) {
+		return function
+	}
+
+	args := evalExpressions(rest, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+
+	return applyFunction(function, append([]object.Object{left}, args...), env)
+}
+
+// applyFunction calls fn with args from env. The body of a Karma function
+// runs in a new environment enclosed by the one fn was defined in, with
+// each parameter bound to the matching argument; a builtin runs with the
+// configuration of env.
+func applyFunction(fn object.Object, args []object.Object, env *object.Environment) object.Object {
+	if builtin, ok := fn.(*object.Builtin); ok {
+		return builtin.Fn(env.Config(), args...)
+	}
+
+	function, ok := fn.(*object.Function)
+	if !ok {
+		return newError("not a function: %s", fn.Type())
+	}
+
+	if len(args) != len(function.Parameters) {
+		return newError("wrong number of arguments: want=%d, got=%d", len(function.Parameters), len(args))
+	}
+
+	extendedEnv := extendFunctionEnv(function, args)
+	evaluated := Eval(function.Body, extendedEnv)
+	return unwrapReturnValue(evaluated)
+}
+
+func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+	env := object.NewEnclosedEnvironment(fn.Env)
+
+	for paramIdx, param := range fn.Parameters {
+		env.Set(param.Value, args[paramIdx])
+	}
+
+	return env
+}
+
+// unwrapReturnValue stops a return value from unwinding past the function
+// it was returned from.
+func unwrapReturnValue(obj object.Object) object.Object {
+	if returnValue, ok := obj.(*object.ReturnValue); ok {
+		return returnValue.Value
+	}
+
+	return obj
+}
+
+// isTruthy reports whether obj counts as true in a condition. Only false
+// and null are falsy.
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case NULL, FALSE, nil:
+		return false
+	default:
+		return true
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// toFloat converts an Integer or Float to a float64.
+func toFloat(obj object.Object) float64 {
+	if i, ok := obj.(*object.Integer); ok {
+		return float64(i.Value)
+	}
+	return obj.(*object.Float).Value
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return TRUE
+	}
+	return FALSE
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return object.NewError(format, a...)
+}
+
+// isError reports whether obj stops evaluation: a runtime error, a call
+// to exit, or a break or continue on its way out to the enclosing loop,
+// which may come from an if used as a value.
+func isError(obj object.Object) bool {
+	if obj == nil {
+		return false
+	}
+	switch obj.Type() {
+	case object.ERROR_OBJ, object.EXIT_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,865 @@
+package evaluator
+
+import (
+	"io"
+	"karma/lexer"
+	"karma/object"
+	"karma/parser"
+	"os"
+	"testing"
+)
+
+func TestEvalIntegerExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5", 5},
+		{"10", 10},
+		{"-5", -5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"20 + 2 * -10", 0},
+		{"50 / 2 * 2 + 10", 60},
+		{"2 * (5 + 10)", 30},
+		{"3 * 3 * 3 + 10", 37},
+		{"3 * (3 * 3) + 10", 37},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"17 % 5", 2},
+		{"0x10 + 0b11", 19},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.5", 3.5},
+		{"-2.5", -2.5},
+		{"1.5 + 1", 2.5},
+		{"1 + 1.5", 2.5},
+		{"7 / 2.0", 3.5},
+		{"1e3 * 2", 2000},
+		{"7.5 % 2", 1.5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalBooleanExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 <= 1", true},
+		{"2 >= 3", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 == 2", false},
+		{"1 != 2", true},
+		{"1 == 1.0", true},
+		{"0.5 < 1", true},
+		{"true == true", true},
+		{"false == false", true},
+		{"true == false", false},
+		{"true != false", true},
+		{"(1 < 2) == true", true},
+		{"(1 > 2) == true", false},
+		{`"a" == "a"`, true},
+		{`"a" < "b"`, true},
+		{`"a" != "a"`, false},
+		{"true && false", false},
+		{"true && 1", true},
+		{"false || 1 > 0", true},
+		{"false || false", false},
+		{"false && undefined", false},
+		{"true || undefined", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestBangOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!false", false},
+		{"!!5", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestStringExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"Hello World!"`, "Hello World!"},
+		{`"Hello" + " " + "World!"`, "Hello World!"},
+		{`karma name = "amer"; "hi ${name}!"`, "hi amer!"},
+		{`karma n = 2; "${n} + ${n} = ${n + n}"`, "2 + 2 = 4"},
+		{`"${true} ${1.0} ${"nested ${1}"}"`, "true 1.0 nested 1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. want=%q, got=%q", tt.expected, str.Value)
+		}
+	}
+}
+
+func TestIfElseExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (1 > 2) { 10 } else if (2 > 1) { 30 } else { 20 }", 30},
+		{"if (true) { }", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{`
+if (10 > 1) {
+  if (10 > 1) {
+    return 10;
+  }
+
+  return 1;
+}
+`, 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
+		{"5 + true; 5;", "type mismatch: INTEGER + BOOLEAN"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"5; true + false; 5", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"if (10 > 1) { true + false; }", "unknown operator: BOOLEAN + BOOLEAN"},
+		{`
+if (10 > 1) {
+  if (10 > 1) {
+    return true + false;
+  }
+
+  return 1;
+}
+`, "unknown operator: BOOLEAN + BOOLEAN"},
+		{"foobar", "identifier not found: foobar"},
+		{`"Hello" - "World"`, "unknown operator: STRING - STRING"},
+		{"10 / 0", "division by zero: 10 / 0"},
+		{"10 % 0", "division by zero: 10 % 0"},
+		{"x = 1", "cannot assign to undefined variable x; declare it with karma x = ..."},
+		{`karma s = "a"; s -= 1`, "type mismatch: STRING - INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"karma a = 5; a;", 5},
+		{"karma a = 5 * 5; a;", 25},
+		{"karma a = 5; karma b = a; b;", 5},
+		{"karma a = 5; karma b = a; karma c = a + b + 5; c;", 15},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"karma a = 5; a = 7; a;", 7},
+		{"karma a = 5; a += 2;", 7},
+		{"karma a = 5; a -= 2; a;", 3},
+		{"karma a = 5; a *= 2; a;", 10},
+		{"karma a = 5; a /= 2; a;", 2},
+		{"karma a = 1; karma b = 2; a = b = 9; a + b;", 18},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestFunctionObject(t *testing.T) {
+	input := "fun(x) { x + 2; };"
+
+	evaluated := testEval(input)
+	fn, ok := evaluated.(*object.Function)
+	if !ok {
+		t.Fatalf("object is not Function. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(fn.Parameters) != 1 {
+		t.Fatalf("function has wrong parameters. Parameters=%+v", fn.Parameters)
+	}
+
+	if fn.Parameters[0].String() != "x" {
+		t.Fatalf("parameter is not 'x'. got=%q", fn.Parameters[0])
+	}
+
+	expectedBody := "{ (x + 2) }"
+
+	if fn.Body.String() != expectedBody {
+		t.Fatalf("body is not %q. got=%q", expectedBody, fn.Body.String())
+	}
+}
+
+func TestFunctionApplication(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"karma identity = fun(x) { x; }; identity(5);", 5},
+		{"karma identity = fun(x) { return x; }; identity(5);", 5},
+		{"karma double = fun(x) { x * 2; }; double(5);", 10},
+		{"karma add = fun(x, y) { x + y; }; add(5, 5);", 10},
+		{"karma add = fun(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fun(x) { x; }(5)", 5},
+		{"karma f = fun() { if (true) { return 1; } return 2; }; f() + 10;", 11},
+		{"karma fib = fun(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }; fib(15);", 610},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestClosures(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`
+karma newAdder = fun(x) {
+  fun(y) { x + y };
+};
+
+karma addTwo = newAdder(2);
+addTwo(2);`, 4},
+		{`
+karma counter = fun() {
+  karma count = 0;
+  fun() { count += 1 };
+};
+
+karma next = counter();
+next();
+next();
+next();`, 3},
+		{`
+karma x = 10;
+karma shadow = fun(x) { x = x + 1; x };
+shadow(1) + x;`, 12},
+		{`
+karma twice = fun(f, x) { f(f(x)) };
+karma inc = fun(n) { n + 1 };
+twice(inc, 5);`, 7},
+		{`
+karma compose = fun(f, g) { fun(x) { g(f(x)) } };
+karma square = fun(n) { n * n };
+karma dec = fun(n) { n - 1 };
+compose(square, dec)(4);`, 15},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestPipeExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"karma double = fun(x) { x * 2 }; 5 |> double", 10},
+		{"karma sub = fun(a, b) { a - b }; 10 |> sub(3)", 7},
+		{"karma inc = fun(x) { x + 1 }; karma double = fun(x) { x * 2 }; 1 + 2 |> inc |> double", 8},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestFunctionErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"karma add = fun(x, y) { x + y }; add(1);", "wrong number of arguments: want=2, got=1"},
+		{"karma f = fun() { 1 }; f(1, 2);", "wrong number of arguments: want=0, got=2"},
+		{"5(1)", "not a function: INTEGER"},
+		{"karma f = fun(x) { x }; f(nope)", "identifier not found: nope"},
+		{"karma f = fun() { karma local = 1; }; f(); local", "identifier not found: local"},
+		{"1 |> 2", "not a function: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestScriptBuiltins(t *testing.T) {
+	config := &object.Config{Output: io.Discard, Args: []string{"first", "second"}}
+	os.Setenv("KARMA_TEST_VAR", "set")
+	defer os.Unsetenv("KARMA_TEST_VAR")
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"args()", `["first", "second"]`},
+		{"args()[1]", "second"},
+		{"len(args())", 2},
+		{`env("KARMA_TEST_VAR")`, "set"},
+		{`env("KARMA_TEST_UNSET")`, nil},
+		{`karma args = fun() { 7 }; args()`, 7},
+		{"args(1)", "ERROR: wrong number of arguments to `args`: want=0, got=1"},
+		{"env()", "ERROR: wrong number of arguments to `env`: want=1, got=0"},
+		{"env(1)", "ERROR: argument to `env` must be STRING, got INTEGER"},
+		{"exit(256)", "ERROR: exit code out of range: 256; want 0 to 255"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalConfigured(tt.input, config)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			got := evaluated.Inspect()
+			if str, ok := evaluated.(*object.String); ok {
+				got = str.Value
+			}
+			if got != expected {
+				t.Errorf("%s: wrong result. want=%q, got=%q", tt.input, expected, got)
+			}
+		}
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("héllo")`, 5},
+		{`len([1, 2, 3])`, 3},
+		{`len({"a": 1})`, 1},
+		{`len(1)`, "ERROR: argument to `len` must be STRING, ARRAY or HASH, got INTEGER"},
+		{`len("one", "two")`, "ERROR: wrong number of arguments to `len`: want=1, got=2"},
+		{`push([1], 2)`, "[1, 2]"},
+		{`karma xs = [1]; push(xs, 2); xs`, "[1]"},
+		{`push(1, 2)`, "ERROR: argument 1 to `push` must be ARRAY, got INTEGER"},
+		{`push([])`, "ERROR: wrong number of arguments to `push`: want=2, got=1"},
+		{`first([1, 2])`, 1},
+		{`first([])`, nil},
+		{`first("a")`, "ERROR: argument to `first` must be ARRAY, got STRING"},
+		{`rest([1, 2, 3])`, "[2, 3]"},
+		{`rest([1])`, "[]"},
+		{`rest([])`, nil},
+		{`keys({"b": 1, "a": 2})`, `["b", "a"]`},
+		{`keys([])`, "ERROR: argument to `keys` must be HASH, got ARRAY"},
+		{`type(1)`, "INTEGER"},
+		{`type(len)`, "BUILTIN"},
+		{`type(fun() {})`, "FUNCTION"},
+		{`str(12)`, "12"},
+		{`str("a")`, "a"},
+		{`str([1, "a"])`, `[1, "a"]`},
+		{`int("42")`, 42},
+		{`int("-7")`, -7},
+		{`int(3.9)`, 3},
+		{`int(-3.9)`, -3},
+		{`int(true)`, 1},
+		{`int(false)`, 0},
+		{`int(5)`, 5},
+		{`int("4x")`, `ERROR: cannot convert "4x" to INTEGER`},
+		{`int(1e30)`, "ERROR: cannot convert 1e+30 to INTEGER"},
+		{`int([])`, "ERROR: argument to `int` must be INTEGER, FLOAT, STRING or BOOLEAN, got ARRAY"},
+		{`int()`, "ERROR: wrong number of arguments to `int`: want=1, got=0"},
+		{`karma len = fun(x) { 0 }; len("abc")`, 0},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			got := evaluated.Inspect()
+			if str, ok := evaluated.(*object.String); ok {
+				got = str.Value
+			}
+			if got != expected {
+				t.Errorf("%s: wrong result. want=%q, got=%q", tt.input, expected, got)
+			}
+		}
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"karma i = 0; while (i < 10) { i += 1 }; i", 10},
+		{"karma i = 0; while (false) { i += 1 }; i", 0},
+		{"karma sum = 0; for (x in [1, 2, 3]) { sum += x }; sum", 6},
+		{"karma sum = 0; for (i in 0..5) { sum += i }; sum", 10},
+		{"karma n = 0; for (i in 5..0) { n += 1 }; n", 0},
+		{`karma s = ""; for (ch in "héllo") { s = ch + s }; s`, "olléh"},
+		{`karma s = ""; for (k in {"b": 1, "a": 2}) { s += k }; s`, "ba"},
+		{"karma i = 0; while (true) { i += 1; if (i == 3) { break } }; i", 3},
+		{"karma sum = 0; for (i in 0..10) { if (i % 2 == 0) { continue }; sum += i }; sum", 25},
+		{"karma n = 0; for (i in 0..3) { for (j in 0..3) { if (j == 1) { break }; n += 1 } }; n", 3},
+		{"karma f = fun() { for (i in 0..10) { if (i == 4) { return i } }; -1 }; f()", 4},
+		{"karma i = 0; while (i < 100000) { i += 1 }; i", 100000},
+		{"for (x in [1]) { karma inner = x }; inner", "identifier not found: inner"},
+		{"karma x = 7; for (x in [1, 2]) { }; x", 7},
+		{"karma fs = []; for (i in 0..3) { fs = push(fs, fun() { i }) }; fs[0]() + fs[2]()", 2},
+		{"for (x in 5) { }", "cannot iterate over INTEGER"},
+		{"while (nope) { }", "identifier not found: nope"},
+		{"for (x in [1, 2]) { x + true }", "type mismatch: INTEGER + BOOLEAN"},
+		{"karma i = 0; while (true) { i += 1; if (i == 2) { exit(9) } }", "exit(9)"},
+		{"karma i = 0; while (i < 3) { i += 1; karma y = if (i == 2) { break; } else { i }; }; i", 2},
+		{"karma n = 0; for (i in 0..4) { karma y = if (i % 2 == 0) { continue } else { i }; n += y }; n", 4},
+		{"karma xs = []; for (i in 0..5) { xs = push(xs, if (i == 3) { break } else { i }) }; xs", "[0, 1, 2]"},
+		{"karma i = 0; while (true) { i += 1; 1 + if (i == 2) { break } else { 0 } }; i", 2},
+		{"karma f = fun() { while (true) { return if (true) { break } else { 1 } }; 5 }; f()", 5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			var got string
+			switch obj := evaluated.(type) {
+			case *object.String:
+				got = obj.Value
+			case *object.Error:
+				got = obj.Message
+			default:
+				got = evaluated.Inspect()
+			}
+			if got != expected {
+				t.Errorf("%s: wrong result. want=%q, got=%q", tt.input, expected, got)
+			}
+		}
+	}
+}
+
+func TestRangeExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1..4", "1..4"},
+		{"karma n = 3; 0..n * 2", "0..6"},
+		{"type(0..1)", `"RANGE"`},
+		{"1.5..2", "ERROR: unknown operator: FLOAT .. FLOAT"},
+	}
+
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestExit(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedCode int
+	}{
+		{"exit()", 0},
+		{"exit(3); 1", 3},
+		{"karma f = fun() { if (true) { exit(4) }; 1 }; f(); 2", 4},
+		{"karma x = exit(5); x", 5},
+		{"1 + exit(6)", 6},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		exit, ok := evaluated.(*object.Exit)
+		if !ok {
+			t.Errorf("%s: object is not Exit. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if exit.Code != tt.expectedCode {
+			t.Errorf("%s: wrong exit code. want=%d, got=%d", tt.input, tt.expectedCode, exit.Code)
+		}
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	evaluated := testEval("[1, 2 * 2, 3 + 3]")
+
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d", len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][2]", 3},
+		{"karma i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"karma myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"[1, 2, 3][-1]", 3},
+		{"[1, 2, 3][-3]", 1},
+		{"[[1, 2], [3, 4]][1][0]", 3},
+		{"[1, 2, 3][3]", "index out of range: 3 with length 3"},
+		{"[1, 2, 3][-4]", "index out of range: -4 with length 3"},
+		{"[][0]", "index out of range: 0 with length 0"},
+		{"[1][true]", "index must be INTEGER, got BOOLEAN"},
+		{"1[0]", "index operator not supported: INTEGER"},
+		{"[1][nope]", "identifier not found: nope"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("%s: wrong error message. expected=%q, got=%q", tt.input, expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestArraySliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3, 4][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4][2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:]", "[1, 2, 3, 4]"},
+		{"[1, 2, 3, 4][-2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:-1]", "[1, 2, 3]"},
+		{"[1, 2, 3, 4][2:2]", "[]"},
+		{"[1, 2, 3][1:5]", "ERROR: slice bounds out of range: 1:5 with length 3"},
+		{"[1, 2, 3][2:1]", "ERROR: slice bounds out of range: 2:1 with length 3"},
+		{"[1, 2, 3][-5:]", "ERROR: slice bounds out of range: -2:3 with length 3"},
+		{"[1, 2, 3][\"a\":]", "ERROR: slice bound must be INTEGER, got STRING"},
+		{"\"abc\"[1:]", "ERROR: slice operator not supported: STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestSliceCopiesElements(t *testing.T) {
+	evaluated := testEval("karma xs = [1, 2, 3]; karma ys = xs[:2]; ys; xs")
+
+	xs, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+	if xs.Inspect() != "[1, 2, 3]" {
+		t.Errorf("slicing changed the array. got=%s", xs.Inspect())
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `karma two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := []struct {
+		key   object.Hashable
+		value int64
+	}{
+		{&object.String{Value: "one"}, 1},
+		{&object.String{Value: "two"}, 2},
+		{&object.String{Value: "three"}, 3},
+		{&object.Integer{Value: 4}, 4},
+		{TRUE, 5},
+		{FALSE, 6},
+	}
+
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", result.Len())
+	}
+
+	for i, pair := range result.Pairs() {
+		if pair.Key.Inspect() != expected[i].key.Inspect() {
+			t.Errorf("pair %d has wrong key. want=%s, got=%s", i, expected[i].key.Inspect(), pair.Key.Inspect())
+		}
+		value, ok := result.Get(expected[i].key)
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+			continue
+		}
+		testIntegerObject(t, value, expected[i].value)
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`karma key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+		{`{1: 1, 1: 2}[1]`, 2},
+		{`{"a": {"b": 3}}["a"]["b"]`, 3},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestHashErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`{"name": "Monkey"}[fun(x) { x }];`, "unusable as hash key: FUNCTION"},
+		{`{[1]: 2}`, "unusable as hash key: ARRAY"},
+		{`{{}: 2}`, "unusable as hash key: HASH"},
+		{`{1.5: 2}`, "unusable as hash key: FLOAT"},
+		{`{"a": nope}`, "identifier not found: nope"},
+		{`{"a": 1}[1:]`, "slice operator not supported: HASH"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func testEval(input string) object.Object {
+	return testEvalConfigured(input, &object.Config{Output: io.Discard})
+}
+
+// testEvalConfigured evaluates input in a new environment with config.
+func testEvalConfigured(input string, config *object.Config) object.Object {
+	l := lexer.New("", input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewConfiguredEnvironment(config)
+
+	return Eval(program, env)
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
+func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
+	result, ok := obj.(*object.Boolean)
+	if !ok {
+		t.Errorf("object is not Boolean. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%t, want=%t", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
+func testNullObject(t *testing.T, obj object.Object) bool {
+	if obj != NULL {
+		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
+		return false
+	}
+	return true
+}
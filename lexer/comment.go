@@ -0,0 +1,72 @@
+package lexer
+
+import (
+	"karma/token"
+	"strings"
+)
+
+// readComment consumes a comment starting at the current '/' and returns
+// it. The second character decides its kind:
+//
+//	// line comment    runs to the end of the line
+//	/// doc comment    runs to the end of the line
+//	/* block comment   runs to the matching */ and may be nested
+//
+// It reports false if a block comment is not closed before the end of
+// input.
+func (l *Lexer) readComment() (token.Comment, bool) {
+	var out strings.Builder
+	comment := token.Comment{Kind: token.LineComment, Start: l.pos()}
+
+	out.WriteRune(l.ch)
+	l.readChar()
+
+	ok := true
+	if l.ch == '*' {
+		comment.Kind = token.BlockComment
+		ok = l.readBlockComment(&out)
+	} else {
+		for l.ch != '\n' && l.ch != eof {
+			out.WriteRune(l.ch)
+			l.readChar()
+		}
+		text := out.String()
+		if strings.HasPrefix(text, "///") && !strings.HasPrefix(text, "////") {
+			comment.Kind = token.DocComment
+		}
+	}
+
+	comment.Text = out.String()
+	comment.End = l.pos()
+	return comment, ok
+}
+
+// readBlockComment consumes the rest of a block comment whose opening '/'
+// has already been written to out, keeping track of nested /* */ pairs.
+func (l *Lexer) readBlockComment(out *strings.Builder) bool {
+	out.WriteRune(l.ch)
+	l.readChar()
+
+	depth := 1
+	for l.ch != eof {
+		switch {
+		case l.ch == '/' && l.peekChar() == '*':
+			depth++
+			out.WriteString("/*")
+			l.readChar()
+			l.readChar()
+		case l.ch == '*' && l.peekChar() == '/':
+			depth--
+			out.WriteString("*/")
+			l.readChar()
+			l.readChar()
+			if depth == 0 {
+				return true
+			}
+		default:
+			out.WriteRune(l.ch)
+			l.readChar()
+		}
+	}
+	return false
+}
@@ -5,15 +5,26 @@
 // keywords, literals, or operators.
 //
 // This package provides a simple, stateful lexer. It is initialized with a source
-// code string and exposes a single method, NextToken, which returns the next token
-// each time it is called. The lexer does not store or buffer tokens — it simply scans
-// character by character and produces tokens on demand.
+// code string (New) or an io.Reader (NewReader) and exposes a single method,
+// NextToken, which returns the next token each time it is called. The lexer does
+// not store or buffer tokens — it decodes the input one UTF-8 character at a time
+// and produces tokens on demand, so scripts can be streamed from files and pipes.
 //
-// Note: For simplicity, this lexer works with an in-memory string and does not track
-// filenames or line numbers. In a production environment, using an io.Reader and
-// recording position metadata would be preferable.
+// Every token records the file name, line, column and byte offset of its first
+// character (Start) and of the character just past its end (End), so later
+// stages can point at the exact place in the source a problem comes from.
+//
+// Comments (// line, /* nested block */ and /// doc comments) are trivia: the
+// lexer skips them so the parser never sees them, but keeps them in source order
+// for formatters and documentation tools (see Lexer.Comments).
+//
+// The lexer never stops at bad input. Stray characters, unterminated strings or
+// comments and invalid escape sequences are recorded as diagnostics (see
+// Lexer.Errors), each with a position, a message and a suggested fix.
+//
+// Columns count characters, not bytes, while offsets count bytes.
 //
 // Enhancements:
-//   - [ ] Support filename and line number in tokens
-//   - [ ] Unicode support
+//   - [x] Support filename and line number in tokens
+//   - [x] Unicode support
 package lexer
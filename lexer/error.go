@@ -0,0 +1,67 @@
+package lexer
+
+import (
+	"fmt"
+	"karma/token"
+	"unicode/utf8"
+)
+
+// Error is a diagnostic produced while scanning: what went wrong, where,
+// and how the user might fix it.
+type Error struct {
+	Pos token.Position // where the problem starts
+	Msg string         // what is wrong
+	Fix string         // a suggested fix, may be empty
+
+	// Unterminated is set when the input ended inside a string, raw
+	// string, interpolation or block comment, so more input could still
+	// make it valid.
+	Unterminated bool
+}
+
+// Error formats the diagnostic as "pos: msg (fix)".
+func (e Error) Error() string {
+	if e.Fix == "" {
+		return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+	}
+	return fmt.Sprintf("%s: %s (%s)", e.Pos, e.Msg, e.Fix)
+}
+
+// Errors returns the diagnostics reported so far, in source order.
+func (l *Lexer) Errors() []Error {
+	return l.errors
+}
+
+// error records a diagnostic at pos.
+func (l *Lexer) error(pos token.Position, msg, fix string) {
+	l.errors = append(l.errors, Error{Pos: pos, Msg: msg, Fix: fix})
+}
+
+// unterminated records a diagnostic at pos for a construct the input ended
+// inside of.
+func (l *Lexer) unterminated(pos token.Position, msg, fix string) {
+	l.errors = append(l.errors, Error{Pos: pos, Msg: msg, Fix: fix, Unterminated: true})
+}
+
+// illegalCharFixes suggests what the user probably meant when a character
+// that starts no token appears in the source.
+var illegalCharFixes = map[rune]string{
+	'&':  "use '&&' for logical and",
+	'|':  "use '||' for logical or, or '|>' to pipe a value into a function",
+	'.':  "use '..' to build a range",
+	'\'': "strings are written with double quotes or backticks",
+	'#':  "comments start with '//'",
+}
+
+// illegalChar reports the character ch at pos, which starts no token.
+func (l *Lexer) illegalChar(pos token.Position, ch rune, width int) {
+	if ch == utf8.RuneError && width == 1 {
+		l.error(pos, "invalid UTF-8 encoding", "save the file as UTF-8")
+		return
+	}
+	fix, ok := illegalCharFixes[ch]
+	if !ok {
+		fix = "remove this character"
+	}
+	l.error(pos, fmt.Sprintf("unexpected character %q", ch), fix)
+}
@@ -1,89 +1,242 @@
 package lexer
 
-import "karma/token"
+import (
+	"bufio"
+	"io"
+	"karma/token"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// eof is the value of Lexer.ch once the input is exhausted.
+const eof = -1
 
 // Lexer turns Karma source code into a stream of tokens.
+//
+// Input is read through a buffered reader and decoded as UTF-8 one rune at
+// a time, so the lexer never needs the whole program in memory.
 type Lexer struct {
-    input        string
-    position     int  // index of current char in input
-    readPosition int  // index of the next char to read
-    ch           byte // current char
+	filename string
+	r        *bufio.Reader
+	err      error // first read error other than io.EOF
+
+	ch        rune           // current char, eof at end of input
+	chWidth   int            // width of ch in bytes
+	chPos     token.Position // position of ch
+	peek      rune           // char after ch, eof at end of input
+	peekWidth int            // width of peek in bytes
+
+	// comments collects every comment read so far, in source order.
+	comments []token.Comment
+
+	// interpolations holds every string interpolation currently open,
+	// innermost last.
+	interpolations []interpolation
+
+	// errors collects the diagnostics reported so far.
+	errors []Error
 }
 
 // New creates and initializes a new Lexer for the given input string.
-func New(input string) *Lexer {
-	l := &Lexer {input : input}
-	l.readChar()
+// filename is recorded in the position of every token; it may be empty
+// when the input does not come from a file.
+func New(filename, input string) *Lexer {
+	return NewReader(filename, strings.NewReader(input))
+}
+
+// NewReader creates a Lexer that reads UTF-8 encoded source code from r.
+// filename is recorded in the position of every token.
+func NewReader(filename string, r io.Reader) *Lexer {
+	l := &Lexer{
+		filename: filename,
+		r:        bufio.NewReader(r),
+		chPos:    token.Position{Filename: filename, Line: 1, Column: 1},
+	}
+	l.peek, l.peekWidth = l.decode()
+	l.ch, l.chWidth = l.peek, l.peekWidth
+	l.peek, l.peekWidth = l.decode()
+	l.skipShebang()
 	return l
 }
 
-// readChar advances the lexer by one character, updating l.ch, l.position,
-// and l.readPosition.
+// skipShebang skips a "#!" line at the very start of the input, such as
+// "#!/usr/bin/env karma", so that scripts can be run directly. The line
+// break is left for skipWhitespace, keeping positions in step with the
+// source.
+func (l *Lexer) skipShebang() {
+	if l.ch != '#' || l.peek != '!' {
+		return
+	}
+	for l.ch != '\n' && l.ch != eof {
+		l.readChar()
+	}
+}
+
+// Comments returns the comments the lexer has skipped so far, in source
+// order. The parser never sees comments; tools that need them can read
+// them here once the input has been consumed.
+func (l *Lexer) Comments() []token.Comment {
+	return l.comments
+}
+
+// Err returns the first error, other than io.EOF, encountered while reading
+// the input. The lexer treats such an error as the end of input.
+func (l *Lexer) Err() error {
+	return l.err
+}
+
+// decode reads the next rune from the underlying reader. Bytes that are not
+// valid UTF-8 decode to utf8.RuneError with a width of 1.
+func (l *Lexer) decode() (rune, int) {
+	if l.err != nil {
+		return eof, 0
+	}
+	ch, width, err := l.r.ReadRune()
+	if err != nil {
+		if err != io.EOF {
+			l.err = err
+		}
+		return eof, 0
+	}
+	return ch, width
+}
+
+// readChar advances the lexer by one character, updating l.ch and the
+// position of the current character.
 func (l *Lexer) readChar() {
-	if l.readPosition >= len(l.input) {
-		l.ch = 0
+	if l.ch == eof {
+		return
+	}
+	l.chPos.Offset += l.chWidth
+	if l.ch == '\n' {
+		l.chPos.Line++
+		l.chPos.Column = 1
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.chPos.Column++
 	}
-	l.position = l.readPosition
-	l.readPosition++
+	l.ch, l.chWidth = l.peek, l.peekWidth
+	l.peek, l.peekWidth = l.decode()
+}
+
+// pos returns the source position of the current character.
+func (l *Lexer) pos() token.Position {
+	return l.chPos
 }
 
 // NextToken scans the next token from the input and returns it.
-// It skips over whitespace and handles single-character operators,
+// It skips over whitespace and comments and handles single-character operators,
 // multi-character operators (like ==, !=), identifiers, keywords, and numbers.
+// The returned token carries the source positions of its first character
+// and of the character just past its end.
+//
+// Input that cannot be scanned yields an ILLEGAL token; the reason, and a
+// suggested fix, is recorded in Errors.
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhitespace()
+	for l.ch == '/' && (l.peekChar() == '/' || l.peekChar() == '*') {
+		comment, ok := l.readComment()
+		l.comments = append(l.comments, comment)
+		if !ok {
+			l.unterminated(comment.Start, "unterminated block comment", "add a closing '*/'")
+			return token.Token{Type: token.ILLEGAL, Literal: comment.Text, Start: comment.Start, End: comment.End}
+		}
+		l.skipWhitespace()
+	}
+	start := l.pos()
 
 	switch l.ch {
-		case '=': 
-			tok = l.makeTwoCharToken('=', token.EQ, token.ASSIGN)
-		case '+': 
-			tok = newToken(token.PLUS, l.ch)
-		case '-':
-			tok = newToken(token.MINUS, l.ch)
-		case '*':
-			tok = newToken(token.ASTERISK, l.ch)
-		case '/':
-			tok = newToken(token.SLASH, l.ch)
-		case '!':
-			tok = l.makeTwoCharToken('=', token.NOT_EQ, token.BANG)
-		case '<':
-			tok = newToken(token.LT, l.ch)
-		case '>':
-			tok = newToken(token.GT, l.ch)
-		case '(': 
-			tok = newToken(token.LPAREN, l.ch)
-		case ')': 
-			tok = newToken(token.RPAREN, l.ch)
-		case '{': 
-			tok = newToken(token.LBRACE, l.ch)
-		case '}': 
-			tok = newToken(token.RBRACE, l.ch)
-		case ',': 
-			tok = newToken(token.COMMA, l.ch)
-		case ';': 
-			tok = newToken(token.SEMICOLON, l.ch)
-		case 0: 
-			tok.Type = token.EOF
-			tok.Literal = ""
-		default:
-			if isLetter(l.ch) {
-				tok.Literal = l.readIdentifier()
-				tok.Type = token.LookupIdent(tok.Literal)
-				return tok
-			} else if isDigit(l.ch) {
-				tok.Type = token.INT
-				tok.Literal = l.readNumber()
-				return tok
-			} else {
-				tok = newToken(token.ILLEGAL, l.ch)
+	case '=':
+		tok = l.makeTwoCharToken(token.ASSIGN)
+	case '+':
+		tok = l.makeTwoCharToken(token.PLUS)
+	case '-':
+		tok = l.makeTwoCharToken(token.MINUS)
+	case '*':
+		tok = l.makeTwoCharToken(token.ASTERISK)
+	case '/':
+		tok = l.makeTwoCharToken(token.SLASH)
+	case '!':
+		tok = l.makeTwoCharToken(token.BANG)
+	case '<':
+		tok = l.makeTwoCharToken(token.LT)
+	case '>':
+		tok = l.makeTwoCharToken(token.GT)
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
+	case '&', '|', '.':
+		tok = l.makeTwoCharToken(token.ILLEGAL)
+	case '(':
+		tok = newToken(token.LPAREN, l.ch)
+	case ')':
+		tok = newToken(token.RPAREN, l.ch)
+	case '{':
+		if n := len(l.interpolations); n > 0 {
+			l.interpolations[n-1].braces++
+		}
+		tok = newToken(token.LBRACE, l.ch)
+	case '}':
+		if n := len(l.interpolations); n > 0 {
+			if l.interpolations[n-1].braces == 0 {
+				l.interpolations = l.interpolations[:n-1]
+				l.readChar()
+				return l.locate(l.readString(start, false), start)
 			}
+			l.interpolations[n-1].braces--
+		}
+		tok = newToken(token.RBRACE, l.ch)
+	case '"':
+		l.readChar()
+		return l.locate(l.readString(start, true), start)
+	case '`':
+		return l.locate(l.readRawString(), start)
+	case '[':
+		tok = newToken(token.LBRACKET, l.ch)
+	case ']':
+		tok = newToken(token.RBRACKET, l.ch)
+	case ':':
+		tok = newToken(token.COLON, l.ch)
+	case ',':
+		tok = newToken(token.COMMA, l.ch)
+	case ';':
+		tok = newToken(token.SEMICOLON, l.ch)
+	case eof:
+		if len(l.interpolations) > 0 {
+			l.unterminated(l.interpolations[0].start, "unterminated string interpolation",
+				`close the interpolation with '}' and the string with '"'`)
+			l.interpolations = nil
+		}
+		tok.Type = token.EOF
+		tok.Literal = ""
+		return l.locate(tok, start)
+	default:
+		if isLetter(l.ch) {
+			tok.Literal = l.readIdentifier()
+			tok.Type = token.LookupIdent(tok.Literal)
+			return l.locate(tok, start)
+		} else if isDigit(l.ch) {
+			tok.Type, tok.Literal = l.readNumber()
+			return l.locate(tok, start)
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
+	}
+
+	if tok.Type == token.ILLEGAL {
+		l.illegalChar(start, l.ch, l.chWidth)
 	}
-	
+
 	l.readChar()
+	return l.locate(tok, start)
+}
+
+// locate sets the span of tok, which started at start and ends at the
+// current character.
+func (l *Lexer) locate(tok token.Token, start token.Position) token.Token {
+	tok.Start = start
+	tok.End = l.pos()
 	return tok
 }
 
@@ -91,35 +244,38 @@ func (l *Lexer) NextToken() token.Token {
 //
 // It’s a helper for single-character tokens such as '+', '-', '{', '}'.
 // Multi-character tokens like identifiers or numbers are handled separately.
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{
-		Type: tokenType,
+		Type:    tokenType,
 		Literal: string(ch),
 	}
 }
 
 // isLetter reports whether ch is a valid identifier letter in Karma.
-// Letters include A–Z, a–z, and underscore (_).
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+// Letters include any Unicode letter and underscore (_).
+func isLetter(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' ||
+		ch >= utf8.RuneSelf && unicode.IsLetter(ch)
 }
 
 // isDigit reports whether ch is an ASCII digit 0–9.
-func isDigit(ch byte) bool{
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 
 	// also valid for ASCII version
 	// return 48 <= ch && ch <= 57
 }
 
-// readIdentifier consumes an identifier from the input starting at l.position.
-// Identifiers consist of letters and underscores. It returns the identifier string.
-func (l *Lexer) readIdentifier() string{
-	position := l.position
-	for isLetter(l.ch) {
+// readIdentifier consumes an identifier from the input starting at the current character.
+// Identifiers start with a letter or underscore, which may be followed by letters,
+// underscores and digits. It returns the identifier string.
+func (l *Lexer) readIdentifier() string {
+	var out strings.Builder
+	for isLetter(l.ch) || isDigit(l.ch) || l.ch >= utf8.RuneSelf && unicode.IsDigit(l.ch) {
+		out.WriteRune(l.ch)
 		l.readChar()
 	}
-	return l.input[position:l.position]
+	return out.String()
 }
 
 // skipWhitespace advances the lexer past any whitespace characters:
@@ -130,35 +286,117 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
-// readNumber consumes a contiguous run of digits from the input starting at l.position.
-// It returns the number literal as a string.
-func (l *Lexer) readNumber() string{
-	position := l.position;
-	for isDigit(l.ch) {
+// readNumber consumes a numeric literal starting at the current character
+// and returns its type (INT or FLOAT) and text. It accepts:
+//
+//	decimal integers    42, 1_000_000
+//	prefixed integers   0x2A, 0o52, 0b101010
+//	floats              3.14, 1e-9, 6.022_140e23
+//
+// The lexer is deliberately lenient: it reads any run of characters that
+// could belong to the literal, along with any letters and digits directly
+// after it, so malformed input such as "0x", "0b102", "1e" or "9a" becomes
+// a single token that the parser can report precisely.
+func (l *Lexer) readNumber() (token.TokenType, string) {
+	var out strings.Builder
+
+	if l.ch == '0' && isBasePrefix(l.peekChar()) {
+		out.WriteRune(l.ch)
+		l.readChar()
+		out.WriteRune(l.ch)
+		l.readChar()
+		for isHexDigit(l.ch) || l.ch == '_' {
+			out.WriteRune(l.ch)
+			l.readChar()
+		}
+		l.readTrailing(&out)
+		return token.INT, out.String()
+	}
+
+	tokenType := token.TokenType(token.INT)
+	l.readDigits(&out)
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		out.WriteRune(l.ch)
+		l.readChar()
+		l.readDigits(&out)
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		tokenType = token.FLOAT
+		out.WriteRune(l.ch)
 		l.readChar()
+		if l.ch == '+' || l.ch == '-' {
+			out.WriteRune(l.ch)
+			l.readChar()
+		}
+		l.readDigits(&out)
 	}
 
-	return l.input[position : l.position]
+	l.readTrailing(&out)
+	return tokenType, out.String()
 }
 
-// peekChar returns the next character without advancing the lexer.
-// If the end of input is reached, it returns 0.
-func (l *Lexer) peekChar() byte{
-	if l.readPosition >= len(l.input) {
-		return 0
-	} else {
-		return l.input[l.readPosition]
+// readTrailing consumes the letters and digits that directly follow a
+// number into out. They are never part of a valid number, but reading
+// them keeps "9a" from becoming the number 9 followed by the name a.
+func (l *Lexer) readTrailing(out *strings.Builder) {
+	for isLetter(l.ch) || isDigit(l.ch) {
+		out.WriteRune(l.ch)
+		l.readChar()
 	}
 }
 
-// makeTwoCharToken checks if the next character matches expectedChar to form
-// a two-character operator (like == or !=). If so, it consumes the second
-// character and returns the combined token. Otherwise it returns the single-char token.
-func (l *Lexer) makeTwoCharToken(expectedChar byte, twoCharType, singleCharType token.TokenType) token.Token {
-    if l.peekChar() == expectedChar {
-        ch := l.ch
-        l.readChar()
-        return token.Token{Type: twoCharType, Literal: string(ch) + string(l.ch)}
-    }
-    return newToken(singleCharType, l.ch)
-}
\ No newline at end of file
+// readDigits consumes a run of decimal digits and '_' separators into out.
+func (l *Lexer) readDigits(out *strings.Builder) {
+	for isDigit(l.ch) || l.ch == '_' {
+		out.WriteRune(l.ch)
+		l.readChar()
+	}
+}
+
+// isBasePrefix reports whether ch, following a leading 0, starts a
+// hexadecimal (x), octal (o) or binary (b) integer literal.
+func isBasePrefix(ch rune) bool {
+	switch ch {
+	case 'x', 'X', 'o', 'O', 'b', 'B':
+		return true
+	}
+	return false
+}
+
+// peekChar returns the next character without advancing the lexer.
+// If the end of input is reached, it returns eof.
+func (l *Lexer) peekChar() rune {
+	return l.peek
+}
+
+// twoCharOperators maps the first character of every two-character operator
+// to the characters that may follow it and the resulting token type.
+var twoCharOperators = map[rune]map[rune]token.TokenType{
+	'=': {'=': token.EQ, '>': token.FAT_ARROW},
+	'!': {'=': token.NOT_EQ},
+	'<': {'=': token.LT_EQ},
+	'>': {'=': token.GT_EQ},
+	'+': {'=': token.PLUS_ASSIGN},
+	'-': {'=': token.MINUS_ASSIGN, '>': token.ARROW},
+	'*': {'=': token.ASTERISK_ASSIGN},
+	'/': {'=': token.SLASH_ASSIGN},
+	'&': {'&': token.AND},
+	'|': {'|': token.OR, '>': token.PIPE},
+	'.': {'.': token.DOT_DOT},
+}
+
+// makeTwoCharToken checks if the current and next characters form one of the
+// twoCharOperators (like == or ->). If so, it consumes the second character
+// and returns the combined token. Otherwise it returns the single-char token,
+// so the longest possible operator always wins.
+func (l *Lexer) makeTwoCharToken(singleCharType token.TokenType) token.Token {
+	if twoCharType, ok := twoCharOperators[l.ch][l.peekChar()]; ok {
+		ch := l.ch
+		l.readChar()
+		return token.Token{Type: twoCharType, Literal: string(ch) + string(l.ch)}
+	}
+	return newToken(singleCharType, l.ch)
+}
@@ -2,6 +2,7 @@ package lexer
 
 import (
 	"karma/token"
+	"strings"
 	"testing"
 )
 
@@ -38,7 +39,7 @@ func TestNextToken(t *testing.T) {
 		};
 		karma result = add(five, ten);
 
-		!-/*5;
+		!-/ *5;
 		5 < 10 > 5;
 
 		if (5 < 10) {
@@ -131,7 +132,7 @@ func TestNextToken(t *testing.T) {
 }
 
 func runLexerTest(t *testing.T, input string, expectedTokens []expectedToken) {
-	l := New(input)
+	l := New("", input)
 
 	for i, tt := range expectedTokens {
 		tok := l.NextToken()
@@ -147,3 +148,447 @@ func runLexerTest(t *testing.T, input string, expectedTokens []expectedToken) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	pos := func(offset, line, column int) token.Position {
+		return token.Position{Filename: "main.ka", Offset: offset, Line: line, Column: column}
+	}
+	input := "karma x = 10;\n  x == 5"
+
+	tests := []struct {
+		expectedType  token.TokenType
+		expectedStart token.Position
+		expectedEnd   token.Position
+	}{
+		{token.KARMA, pos(0, 1, 1), pos(5, 1, 6)},
+		{token.IDENT, pos(6, 1, 7), pos(7, 1, 8)},
+		{token.ASSIGN, pos(8, 1, 9), pos(9, 1, 10)},
+		{token.INT, pos(10, 1, 11), pos(12, 1, 13)},
+		{token.SEMICOLON, pos(12, 1, 13), pos(13, 1, 14)},
+		{token.IDENT, pos(16, 2, 3), pos(17, 2, 4)},
+		{token.EQ, pos(18, 2, 5), pos(20, 2, 7)},
+		{token.INT, pos(21, 2, 8), pos(22, 2, 9)},
+		{token.EOF, pos(22, 2, 9), pos(22, 2, 9)},
+	}
+
+	l := New("main.ka", input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - token type mismatch: expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Start != tt.expectedStart {
+			t.Errorf("tests[%d] - start mismatch: expected=%+v, got=%+v", i, tt.expectedStart, tok.Start)
+		}
+		if tok.End != tt.expectedEnd {
+			t.Errorf("tests[%d] - end mismatch: expected=%+v, got=%+v", i, tt.expectedEnd, tok.End)
+		}
+	}
+}
+
+func TestPositionString(t *testing.T) {
+	tests := []struct {
+		pos      token.Position
+		expected string
+	}{
+		{token.Position{Filename: "main.ka", Line: 3, Column: 7}, "main.ka:3:7"},
+		{token.Position{Line: 3, Column: 7}, "3:7"},
+		{token.Position{Filename: "main.ka"}, "main.ka"},
+		{token.Position{}, "-"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.pos.String(); got != tt.expected {
+			t.Errorf("Position.String() wrong. expected=%q, got=%q", tt.expected, got)
+		}
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	input := `karma größe = 5; karma 名前 = größe;`
+
+	tests := []expectedToken{
+		{token.KARMA, "karma"},
+		{token.IDENT, "größe"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.KARMA, "karma"},
+		{token.IDENT, "名前"},
+		{token.ASSIGN, "="},
+		{token.IDENT, "größe"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	runLexerTest(t, input, tests)
+}
+
+func TestNewReader(t *testing.T) {
+	l := NewReader("pipe", strings.NewReader("karma π = 3;\nπ"))
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedColumn  int
+		expectedOffset  int
+	}{
+		{token.KARMA, "karma", 1, 0},
+		{token.IDENT, "π", 7, 6},
+		{token.ASSIGN, "=", 9, 9},
+		{token.INT, "3", 11, 11},
+		{token.SEMICOLON, ";", 12, 12},
+		{token.IDENT, "π", 1, 14},
+		{token.EOF, "", 2, 16},
+	}
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token mismatch: expected={%s %q}, got={%s %q}", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tok.Start.Column != tt.expectedColumn || tok.Start.Offset != tt.expectedOffset {
+			t.Errorf("tests[%d] - position mismatch: expected column=%d offset=%d, got=%+v", i, tt.expectedColumn, tt.expectedOffset, tok.Start)
+		}
+		if tok.Start.Filename != "pipe" {
+			t.Errorf("tests[%d] - filename mismatch: got=%q", i, tok.Start.Filename)
+		}
+	}
+	if err := l.Err(); err != nil {
+		t.Errorf("l.Err() returned %v", err)
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	tests := []expectedToken{
+		{token.IDENT, "a"},
+		{token.ILLEGAL, "�"},
+		{token.IDENT, "b"},
+		{token.EOF, ""},
+	}
+
+	runLexerTest(t, "a\xffb", tests)
+}
+
+func TestStrings(t *testing.T) {
+	input := `"hello" "a\tb\n" "say \"hi\"" "back\\slash" "\u{48}\u{1F600}" "héllo wörld" ` + "`raw \\n ${x}`" + ` "cost: \$5"`
+
+	tests := []expectedToken{
+		{token.STRING, "hello"},
+		{token.STRING, "a\tb\n"},
+		{token.STRING, `say "hi"`},
+		{token.STRING, `back\slash`},
+		{token.STRING, "H\U0001F600"},
+		{token.STRING, "héllo wörld"},
+		{token.STRING, `raw \n ${x}`},
+		{token.STRING, "cost: $5"},
+		{token.EOF, ""},
+	}
+
+	runLexerTest(t, input, tests)
+}
+
+func TestStringInterpolation(t *testing.T) {
+	input := `"a ${x} b ${f({y})} c" "${n}"`
+
+	tests := []expectedToken{
+		{token.STRING_HEAD, "a "},
+		{token.IDENT, "x"},
+		{token.STRING_MIDDLE, " b "},
+		{token.IDENT, "f"},
+		{token.LPAREN, "("},
+		{token.LBRACE, "{"},
+		{token.IDENT, "y"},
+		{token.RBRACE, "}"},
+		{token.RPAREN, ")"},
+		{token.STRING_TAIL, " c"},
+		{token.STRING_HEAD, ""},
+		{token.IDENT, "n"},
+		{token.STRING_TAIL, ""},
+		{token.EOF, ""},
+	}
+
+	runLexerTest(t, input, tests)
+}
+
+func TestInvalidStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected expectedToken
+	}{
+		{`"unterminated`, expectedToken{token.ILLEGAL, "unterminated"}},
+		{`"bad \q escape"`, expectedToken{token.STRING, `bad \q escape`}},
+		{`"\u{110000}"`, expectedToken{token.STRING, `\u{110000}`}},
+		{`"\u{41"`, expectedToken{token.STRING, `\u{41`}},
+		{"`raw", expectedToken{token.ILLEGAL, "raw"}},
+	}
+
+	for _, tt := range tests {
+		runLexerTest(t, tt.input, []expectedToken{tt.expected})
+	}
+}
+
+func TestLexerErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`karma s = "open;`, []string{`1:11: unterminated string literal (add a closing '"')`}},
+		{"x = `open", []string{"1:5: unterminated raw string literal (add a closing '`')"}},
+		{`"a\qb"`, []string{`1:3: invalid escape sequence '\q' (use \\ for a literal backslash; valid escapes are \n \t \r \" \\ \$ \u{...})`}},
+		{`"\u{zz}"`, []string{`1:2: invalid Unicode escape '\u{' (write \u{XXXX} with 1 to 6 hex digits naming a valid code point)`}},
+		{"a @ b", []string{`1:3: unexpected character '@' (remove this character)`}},
+		{"a & b", []string{`1:3: unexpected character '&' (use '&&' for logical and)`}},
+		{"x\n  # note", []string{`2:3: unexpected character '#' (comments start with '//')`}},
+		{"a\xffb", []string{`1:2: invalid UTF-8 encoding (save the file as UTF-8)`}},
+		{"1 /* open", []string{`1:3: unterminated block comment (add a closing '*/')`}},
+		{`"a ${b`, []string{`1:4: unterminated string interpolation (close the interpolation with '}' and the string with '"')`}},
+		{`"a ${b} c`, []string{`1:7: unterminated string literal (add a closing '"')`}},
+		{`"fine" x1 "\u{1F600}"`, nil},
+	}
+
+	for _, tt := range tests {
+		l := New("", tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+
+		errors := l.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("input %q: wrong number of errors. expected=%d, got=%v", tt.input, len(tt.expected), errors)
+			continue
+		}
+		for i, msg := range tt.expected {
+			if errors[i].Error() != msg {
+				t.Errorf("input %q: errors[%d] wrong.\nexpected=%s\n     got=%s", tt.input, i, msg, errors[i].Error())
+			}
+			if unterminated := strings.Contains(msg, "unterminated"); errors[i].Unterminated != unterminated {
+				t.Errorf("input %q: errors[%d].Unterminated wrong. expected=%t", tt.input, i, unterminated)
+			}
+		}
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	input := `42 1_000_000 0x2A 0o52 0B101010 3.14 1e-9 6.022_140e23 2E10 0x 1e 0b102 7.field 1..5`
+
+	tests := []expectedToken{
+		{token.INT, "42"},
+		{token.INT, "1_000_000"},
+		{token.INT, "0x2A"},
+		{token.INT, "0o52"},
+		{token.INT, "0B101010"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "1e-9"},
+		{token.FLOAT, "6.022_140e23"},
+		{token.FLOAT, "2E10"},
+		{token.INT, "0x"},
+		{token.FLOAT, "1e"},
+		{token.INT, "0b102"},
+		{token.INT, "7"},
+		{token.ILLEGAL, "."},
+		{token.IDENT, "field"},
+		{token.INT, "1"},
+		{token.DOT_DOT, ".."},
+		{token.INT, "5"},
+		{token.EOF, ""},
+	}
+
+	runLexerTest(t, input, tests)
+}
+
+func TestComments(t *testing.T) {
+	input := `/// Adds two numbers.
+karma add = 1; // trailing note
+/* block /* nested */ still comment */ add /**/ / 2;
+//// not a doc comment
+`
+
+	tests := []expectedToken{
+		{token.KARMA, "karma"},
+		{token.IDENT, "add"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "add"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New("", input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token mismatch: expected={%s %q}, got={%s %q}", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+
+	expected := []struct {
+		kind token.CommentKind
+		text string
+		line int
+	}{
+		{token.DocComment, "/// Adds two numbers.", 1},
+		{token.LineComment, "// trailing note", 2},
+		{token.BlockComment, "/* block /* nested */ still comment */", 3},
+		{token.BlockComment, "/**/", 3},
+		{token.LineComment, "//// not a doc comment", 4},
+	}
+
+	comments := l.Comments()
+	if len(comments) != len(expected) {
+		t.Fatalf("wrong number of comments. expected=%d, got=%d", len(expected), len(comments))
+	}
+	for i, tt := range expected {
+		c := comments[i]
+		if c.Kind != tt.kind || c.Text != tt.text || c.Start.Line != tt.line {
+			t.Errorf("comments[%d] wrong. expected={%d %q line %d}, got={%d %q line %d}", i, tt.kind, tt.text, tt.line, c.Kind, c.Text, c.Start.Line)
+		}
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	tests := []expectedToken{
+		{token.INT, "1"},
+		{token.ILLEGAL, "/* open /* */"},
+		{token.EOF, ""},
+	}
+
+	runLexerTest(t, "1 /* open /* */", tests)
+}
+
+func TestOperators(t *testing.T) {
+	input := `a <= b >= c && d || e % f
+x += 1; x -= 2; x *= 3; x /= 4;
+-> => .. |> < > = - ! & | . ==> !== <== ->=`
+
+	tests := []expectedToken{
+		{token.IDENT, "a"},
+		{token.LT_EQ, "<="},
+		{token.IDENT, "b"},
+		{token.GT_EQ, ">="},
+		{token.IDENT, "c"},
+		{token.AND, "&&"},
+		{token.IDENT, "d"},
+		{token.OR, "||"},
+		{token.IDENT, "e"},
+		{token.PERCENT, "%"},
+		{token.IDENT, "f"},
+		{token.IDENT, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "4"},
+		{token.SEMICOLON, ";"},
+		{token.ARROW, "->"},
+		{token.FAT_ARROW, "=>"},
+		{token.DOT_DOT, ".."},
+		{token.PIPE, "|>"},
+		{token.LT, "<"},
+		{token.GT, ">"},
+		{token.ASSIGN, "="},
+		{token.MINUS, "-"},
+		{token.BANG, "!"},
+		{token.ILLEGAL, "&"},
+		{token.ILLEGAL, "|"},
+		{token.ILLEGAL, "."},
+		{token.EQ, "=="},
+		{token.GT, ">"},
+		{token.NOT_EQ, "!="},
+		{token.ASSIGN, "="},
+		{token.LT_EQ, "<="},
+		{token.ASSIGN, "="},
+		{token.ARROW, "->"},
+		{token.ASSIGN, "="},
+		{token.EOF, ""},
+	}
+
+	runLexerTest(t, input, tests)
+}
+
+func TestBrackets(t *testing.T) {
+	input := `[1, 2][-1:]`
+
+	runLexerTest(t, input, []expectedToken{
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.LBRACKET, "["},
+		{token.MINUS, "-"},
+		{token.INT, "1"},
+		{token.COLON, ":"},
+		{token.RBRACKET, "]"},
+		{token.EOF, ""},
+	})
+}
+
+func TestLoopKeywords(t *testing.T) {
+	input := `while for in break continue inner`
+
+	runLexerTest(t, input, []expectedToken{
+		{token.WHILE, "while"},
+		{token.FOR, "for"},
+		{token.IN, "in"},
+		{token.BREAK, "break"},
+		{token.CONTINUE, "continue"},
+		{token.IDENT, "inner"},
+		{token.EOF, ""},
+	})
+}
+
+func TestIdentifiersWithDigits(t *testing.T) {
+	input := `x1 = 5; a1b2 _9 _ 9a v2_0 x١ 1x1`
+
+	tests := []expectedToken{
+		{token.IDENT, "x1"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a1b2"},
+		{token.IDENT, "_9"},
+		{token.IDENT, "_"},
+		{token.INT, "9a"},
+		{token.IDENT, "v2_0"},
+		{token.IDENT, "x١"},
+		{token.INT, "1x1"},
+		{token.EOF, ""},
+	}
+
+	runLexerTest(t, input, tests)
+}
+
+func TestShebang(t *testing.T) {
+	l := New("script.ka", "#!/usr/bin/env karma -x\nexit(0)")
+
+	tok := l.NextToken()
+	if tok.Type != token.IDENT || tok.Literal != "exit" {
+		t.Fatalf("shebang line was not skipped. got={%s %q}", tok.Type, tok.Literal)
+	}
+	if tok.Start.Line != 2 || tok.Start.Column != 1 {
+		t.Errorf("wrong position after shebang. got=%s", tok.Start)
+	}
+
+	// only the first line of the input can be a shebang
+	runLexerTest(t, " #!x", []expectedToken{
+		{token.ILLEGAL, "#"},
+		{token.BANG, "!"},
+		{token.IDENT, "x"},
+		{token.EOF, ""},
+	})
+	runLexerTest(t, "#!", []expectedToken{{token.EOF, ""}})
+}
@@ -0,0 +1,163 @@
+package lexer
+
+import (
+	"fmt"
+	"karma/token"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// interpolation tracks a ${ ... } that is currently open inside a string.
+type interpolation struct {
+	start  token.Position // position of the '$'
+	braces int            // unclosed '{' inside the interpolation
+}
+
+// readString consumes one segment of a double-quoted string literal,
+// starting just after the opening quote (head is true) or just after the
+// closing brace of an interpolation (head is false). The segment ends at
+// the closing quote or at the next "${". start is the position of the
+// quote or brace the segment begins with.
+//
+// A string without interpolation is a single STRING token. A string with
+// interpolation is split into a STRING_HEAD, the tokens of each embedded
+// expression separated by STRING_MIDDLE tokens, and a STRING_TAIL.
+// The literal of each of these tokens is the decoded text of the segment.
+//
+// An invalid escape sequence is reported and kept as written. A string
+// that is not closed before the end of input is reported and yields an
+// ILLEGAL token.
+func (l *Lexer) readString(start token.Position, head bool) token.Token {
+	var out strings.Builder
+
+	for {
+		switch l.ch {
+		case eof:
+			l.unterminated(start, "unterminated string literal", `add a closing '"'`)
+			return token.Token{Type: token.ILLEGAL, Literal: out.String()}
+		case '"':
+			l.readChar()
+			if head {
+				return token.Token{Type: token.STRING, Literal: out.String()}
+			}
+			return token.Token{Type: token.STRING_TAIL, Literal: out.String()}
+		case '$':
+			if l.peekChar() == '{' {
+				l.interpolations = append(l.interpolations, interpolation{start: l.pos()})
+				l.readChar()
+				l.readChar()
+				if head {
+					return token.Token{Type: token.STRING_HEAD, Literal: out.String()}
+				}
+				return token.Token{Type: token.STRING_MIDDLE, Literal: out.String()}
+			}
+		case '\\':
+			l.readEscape(&out)
+			continue
+		}
+		out.WriteRune(l.ch)
+		l.readChar()
+	}
+}
+
+// readEscape decodes the escape sequence starting at the current backslash
+// and writes the resulting character to out. An invalid escape sequence is
+// reported and written to out as it appears in the source.
+//
+// Supported escapes are \n, \t, \r, \", \\, \$ and \u{XXXX}, where XXXX
+// is the hexadecimal code point of a Unicode character.
+func (l *Lexer) readEscape(out *strings.Builder) {
+	pos := l.pos()
+	var seq strings.Builder
+	seq.WriteRune(l.ch)
+	l.readChar()
+
+	switch ch := l.ch; ch {
+	case eof:
+		out.WriteString(seq.String())
+	case 'n', 't', 'r', '"', '\\', '$':
+		l.readChar()
+		out.WriteRune(simpleEscapes[ch])
+	case 'u':
+		seq.WriteRune(ch)
+		l.readChar()
+		if code, ok := l.readCodePoint(&seq); ok {
+			out.WriteRune(code)
+			return
+		}
+		l.error(pos, fmt.Sprintf("invalid Unicode escape '%s'", seq.String()),
+			`write \u{XXXX} with 1 to 6 hex digits naming a valid code point`)
+		out.WriteString(seq.String())
+	default:
+		seq.WriteRune(ch)
+		l.readChar()
+		l.error(pos, fmt.Sprintf("invalid escape sequence '%s'", seq.String()),
+			`use \\ for a literal backslash; valid escapes are \n \t \r \" \\ \$ \u{...}`)
+		out.WriteString(seq.String())
+	}
+}
+
+// readCodePoint consumes the "{XXXX}" part of a \u escape into seq and
+// returns the character it names.
+func (l *Lexer) readCodePoint(seq *strings.Builder) (rune, bool) {
+	if l.ch != '{' {
+		return 0, false
+	}
+	seq.WriteRune(l.ch)
+	l.readChar()
+
+	var digits strings.Builder
+	for isHexDigit(l.ch) {
+		digits.WriteRune(l.ch)
+		seq.WriteRune(l.ch)
+		l.readChar()
+	}
+	if l.ch != '}' {
+		return 0, false
+	}
+	seq.WriteRune(l.ch)
+	l.readChar()
+
+	code, err := strconv.ParseUint(digits.String(), 16, 32)
+	if err != nil || !utf8.ValidRune(rune(code)) {
+		return 0, false
+	}
+	return rune(code), true
+}
+
+// simpleEscapes maps the character after a backslash to the character it
+// stands for.
+var simpleEscapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'"':  '"',
+	'\\': '\\',
+	'$':  '$',
+}
+
+// readRawString consumes a backtick-delimited raw string literal starting at
+// the opening backtick. Raw strings may span lines and contain no escape
+// sequences or interpolation. An unterminated raw string is reported and
+// yields an ILLEGAL token.
+func (l *Lexer) readRawString() token.Token {
+	var out strings.Builder
+	start := l.pos()
+	l.readChar()
+	for l.ch != '`' {
+		if l.ch == eof {
+			l.unterminated(start, "unterminated raw string literal", "add a closing '`'")
+			return token.Token{Type: token.ILLEGAL, Literal: out.String()}
+		}
+		out.WriteRune(l.ch)
+		l.readChar()
+	}
+	l.readChar()
+	return token.Token{Type: token.STRING, Literal: out.String()}
+}
+
+// isHexDigit reports whether ch is a hexadecimal digit.
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
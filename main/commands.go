@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"karma/ast"
+	"karma/diagnostic"
+	"karma/evaluator"
+	"karma/lexer"
+	"karma/object"
+	"karma/parser"
+	"karma/token"
+)
+
+// run runs the script named by args[0]. The arguments after it are passed
+// to the script, which reads them with the args builtin.
+func (c *cli) run(args []string) int {
+	if len(args) == 0 {
+		return c.usageError("run needs a file")
+	}
+
+	filename, program, code := c.parseFile(args[0])
+	if code != exitOK {
+		return code
+	}
+
+	env := object.NewConfiguredEnvironment(&object.Config{Output: c.stdout, Args: args[1:]})
+	switch result := evaluator.Eval(program, env).(type) {
+	case *object.Error:
+		fmt.Fprintf(c.stderr, "%s: %s\n", filename, result.Message)
+		return exitError
+	case *object.Exit:
+		return result.Code
+	}
+	return exitOK
+}
+
+// lex prints the tokens of one file, one per line, or as a JSON array
+// with -json. Lexical errors are reported on stderr.
+func (c *cli) lex(args []string) int {
+	fs := c.flags("lex")
+	asJSON := fs.Bool("json", false, "print the tokens as JSON")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		return c.usageError("lex needs exactly one file")
+	}
+
+	filename, src, err := c.readSource(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(c.stderr, "karma: %s\n", err)
+		return exitError
+	}
+
+	l := lexer.New(filename, src)
+	tokens := []jsonToken{}
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if *asJSON {
+			tokens = append(tokens, newJSONToken(tok))
+			continue
+		}
+		fmt.Fprintf(c.stdout, "%-8s %-10s %q\n", lineColumn(tok.Start), tok.Type, tok.Literal)
+	}
+	if *asJSON {
+		c.printJSON(tokens)
+	}
+
+	for _, err := range l.Errors() {
+		fmt.Fprintln(c.stderr, err)
+	}
+	if len(l.Errors()) != 0 || l.Err() != nil {
+		return exitError
+	}
+	return exitOK
+}
+
+// parse prints the syntax tree of one file, as an indented outline or as
+// JSON with -json. Syntax errors are reported on stderr; the tree is still
+// printed, with Bad nodes where the errors are.
+func (c *cli) parse(args []string) int {
+	fs := c.flags("parse")
+	asJSON := fs.Bool("json", false, "print the syntax tree as JSON")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		return c.usageError("parse needs exactly one file")
+	}
+
+	_, program, code := c.parseFile(fs.Arg(0))
+	if program == nil {
+		return code
+	}
+
+	tree := dumpNode(program)
+	if *asJSON {
+		c.printJSON(tree)
+	} else {
+		tree.print(c.stdout, "")
+	}
+	return code
+}
+
+// check parses every file given and reports their syntax errors. It
+// prints nothing when all of them are free of errors.
+func (c *cli) check(args []string) int {
+	if len(args) == 0 {
+		return c.usageError("check needs at least one file")
+	}
+
+	code := exitOK
+	for _, name := range args {
+		if _, _, status := c.parseFile(name); status != exitOK {
+			code = exitError
+		}
+	}
+	return code
+}
+
+// parseFile reads and parses the named file, and returns the name to report
+// it under, its program and the exit status to use. Syntax errors are
+// reported on stderr, and the program returned is incomplete. When the
+// file cannot be read the program is nil.
+func (c *cli) parseFile(name string) (string, *ast.Program, int) {
+	filename, src, err := c.readSource(name)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "karma: %s\n", err)
+		return filename, nil, exitError
+	}
+
+	p := parser.New(lexer.New(filename, src))
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		diagnostic.NewRenderer(c.stderr).RenderAll(p.Diagnostics(), src)
+		return filename, program, exitError
+	}
+	return filename, program, exitOK
+}
+
+// printJSON writes v to stdout as indented JSON.
+func (c *cli) printJSON(v interface{}) {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		// only values built in this package are printed
+		panic(err)
+	}
+	fmt.Fprintf(c.stdout, "%s\n", out)
+}
+
+// lineColumn formats pos without its file name.
+func lineColumn(pos token.Position) string {
+	return fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+}
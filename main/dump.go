@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"karma/token"
+	"reflect"
+	"unicode"
+	"unicode/utf8"
+)
+
+// jsonPosition is the JSON form of a token.Position. The file name is
+// left out, since every position in a dump is in the same file.
+type jsonPosition struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func newJSONPosition(pos token.Position) jsonPosition {
+	return jsonPosition{Offset: pos.Offset, Line: pos.Line, Column: pos.Column}
+}
+
+// jsonToken is the JSON form of a token.Token.
+type jsonToken struct {
+	Type    token.TokenType `json:"type"`
+	Literal string          `json:"literal"`
+	Start   jsonPosition    `json:"start"`
+	End     jsonPosition    `json:"end"`
+}
+
+func newJSONToken(tok token.Token) jsonToken {
+	return jsonToken{
+		Type:    tok.Type,
+		Literal: tok.Literal,
+		Start:   newJSONPosition(tok.Start),
+		End:     newJSONPosition(tok.End),
+	}
+}
+
+// treeNode is a syntax tree node in a form that can be printed as an
+// outline or as JSON: its type, where it starts, and its fields other
+// than the token, in declaration order.
+type treeNode struct {
+	Type   string
+	Start  *token.Position // nil for nodes without a token
+	Fields []treeField
+}
+
+type treeField struct {
+	Name  string
+	Value interface{} // *treeNode, []interface{} or a plain value
+}
+
+var (
+	tokenType    = reflect.TypeOf(token.Token{})
+	positionType = reflect.TypeOf(token.Position{})
+)
+
+// dumpNode converts an ast node into a treeNode by walking its fields.
+// Walking the fields generically keeps the dump in step with the ast
+// package as nodes are added.
+func dumpNode(node interface{}) *treeNode {
+	n, _ := dumpValue(reflect.ValueOf(node)).(*treeNode)
+	return n
+}
+
+func dumpValue(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return dumpValue(v.Elem())
+	case reflect.Slice:
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = dumpValue(v.Index(i))
+		}
+		return items
+	case reflect.Struct:
+		t := v.Type()
+		if t == positionType {
+			return newJSONPosition(v.Interface().(token.Position))
+		}
+		n := &treeNode{Type: t.Name()}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Type == tokenType {
+				start := v.Field(i).Interface().(token.Token).Start
+				n.Start = &start
+				continue
+			}
+			n.Fields = append(n.Fields, treeField{Name: f.Name, Value: dumpValue(v.Field(i))})
+		}
+		return n
+	default:
+		return v.Interface()
+	}
+}
+
+// MarshalJSON encodes n as an object holding its type, its start position
+// and its fields, in declaration order, under lower camel case names.
+func (n *treeNode) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(`{"type":`)
+	typ, _ := json.Marshal(n.Type)
+	buf.Write(typ)
+
+	if n.Start != nil {
+		start, _ := json.Marshal(newJSONPosition(*n.Start))
+		buf.WriteString(`,"start":`)
+		buf.Write(start)
+	}
+
+	for _, f := range n.Fields {
+		value, err := json.Marshal(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, ",%q:", lowerFirst(f.Name))
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// print writes n as an outline, one field per line, with every line
+// prefixed by indent.
+//
+//	LetStatement 1:1
+//	  Name: Identifier 1:7
+//	    Value: "x"
+func (n *treeNode) print(out io.Writer, indent string) {
+	fmt.Fprint(out, n.Type)
+	if n.Start != nil {
+		fmt.Fprintf(out, " %s", lineColumn(*n.Start))
+	}
+	fmt.Fprintln(out)
+
+	indent += "  "
+	for _, f := range n.Fields {
+		fmt.Fprintf(out, "%s%s:", indent, f.Name)
+		printValue(out, f.Value, indent)
+	}
+}
+
+// printValue writes the value of a field, following its name. A list
+// starts on the next line, with one item per line.
+func printValue(out io.Writer, value interface{}, indent string) {
+	switch v := value.(type) {
+	case *treeNode:
+		fmt.Fprint(out, " ")
+		v.print(out, indent)
+	case []interface{}:
+		if len(v) == 0 {
+			fmt.Fprintln(out, " []")
+			return
+		}
+		fmt.Fprintln(out)
+		for _, item := range v {
+			fmt.Fprintf(out, "%s  -", indent)
+			printValue(out, item, indent+"  ")
+		}
+	case jsonPosition:
+		fmt.Fprintf(out, " %d:%d\n", v.Line, v.Column)
+	case nil:
+		fmt.Fprintln(out, " nil")
+	case string:
+		fmt.Fprintf(out, " %q\n", v)
+	default:
+		fmt.Fprintf(out, " %v\n", v)
+	}
+}
+
+// lowerFirst returns s with its first letter in lower case.
+func lowerFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[size:]
+}
@@ -1,20 +1,138 @@
+// Command karma runs Karma programs and hosts the Karma REPL.
+//
+// Usage:
+//
+//	karma                          start the REPL
+//	karma repl                     start the REPL
+//	karma run <file> [args...]     run a script
+//	karma <file> [args...]         run a script, as in a #!/usr/bin/env karma line
+//	karma lex [-json] <file>       print the tokens of a file
+//	karma parse [-json] <file>     print the syntax tree of a file
+//	karma check <file>...          report syntax errors
+//
+// A file named - is read from standard input. karma exits with status 0 on
+// success, 1 when the program has errors and 2 when it is used wrongly; a
+// script can choose its own status by calling exit.
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"karma/logo"
+	"karma/repl"
 	"os"
 	"os/user"
-	"karma/repl"
-	"karma/logo"
 )
 
+// Exit statuses.
+const (
+	exitOK    = 0
+	exitError = 1 // the program has syntax or runtime errors
+	exitUsage = 2 // karma was invoked wrongly
+)
+
+// stdinName is the file name that stands for standard input, and
+// stdinFilename the name it is given in positions.
+const (
+	stdinName     = "-"
+	stdinFilename = "<stdin>"
+)
+
+const usage = `usage:
+	karma                          start the REPL
+	karma repl                     start the REPL
+	karma run <file> [args...]     run a script
+	karma <file> [args...]         run a script, as in a #!/usr/bin/env karma line
+	karma lex [-json] <file>       print the tokens of a file
+	karma parse [-json] <file>     print the syntax tree of a file
+	karma check <file>...          report syntax errors
+
+A file named - is read from standard input.
+`
+
+// cli holds the streams a command reads and writes.
+type cli struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+}
+
+// subcommands maps the name of each subcommand to its implementation,
+// which receives the arguments following the name and returns the exit
+// status.
+var subcommands = map[string]func(c *cli, args []string) int{
+	"repl":  (*cli).repl,
+	"run":   (*cli).run,
+	"lex":   (*cli).lex,
+	"parse": (*cli).parse,
+	"check": (*cli).check,
+}
+
 func main() {
-	user, err := user.Current()
-	if err != nil {
-		panic(err)
+	c := &cli{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}
+	os.Exit(c.main(os.Args[1:]))
+}
+
+// main runs the command line args and returns the exit status.
+func (c *cli) main(args []string) int {
+	if len(args) == 0 {
+		return c.repl(nil)
+	}
+
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(c.stdout, usage)
+		return exitOK
+	}
+
+	cmd, ok := subcommands[args[0]]
+	if !ok {
+		if _, err := os.Stat(args[0]); err == nil {
+			return c.run(args)
+		}
+		fmt.Fprintf(c.stderr, "karma: unknown command %q\n%s", args[0], usage)
+		return exitUsage
+	}
+	return cmd(c, args[1:])
+}
+
+func (c *cli) repl(args []string) int {
+	if len(args) != 0 {
+		return c.usageError("repl takes no arguments")
+	}
+
+	fmt.Fprintf(c.stdout, "%s\n", logo.KARMA)
+	if u, err := user.Current(); err == nil {
+		fmt.Fprintf(c.stdout, "Hello %s!\n", u.Username)
+	} else {
+		fmt.Fprintln(c.stdout, "Hello!")
 	}
+	return repl.Start(c.stdin, c.stdout)
+}
 
-	fmt.Printf("%s\n", logo.KARMA)
-	fmt.Printf("Hello %s!\n", user.Username)
-	repl.Start(os.Stdin, os.Stdout)
-}
\ No newline at end of file
+// flags returns a flag set for the named subcommand that reports its
+// errors to stderr.
+func (c *cli) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() { fmt.Fprint(c.stderr, usage) }
+	return fs
+}
+
+// usageError reports that karma was invoked wrongly.
+func (c *cli) usageError(msg string) int {
+	fmt.Fprintf(c.stderr, "karma: %s\n%s", msg, usage)
+	return exitUsage
+}
+
+// readSource reads the named file, or standard input for -, and returns
+// the name to use for it in positions along with its contents.
+func (c *cli) readSource(name string) (string, string, error) {
+	if name == stdinName {
+		src, err := ioutil.ReadAll(c.stdin)
+		return stdinFilename, string(src), err
+	}
+	src, err := ioutil.ReadFile(name)
+	return name, string(src), err
+}
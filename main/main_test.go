@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// karma runs the command line args with stdin as standard input and
+// returns the exit status and what was written to stdout and stderr.
+func karma(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	c := &cli{stdin: strings.NewReader(stdin), stdout: &stdout, stderr: &stderr}
+	code := c.main(args)
+	return code, stdout.String(), stderr.String()
+}
+
+func writeFile(t *testing.T, name, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExitCodes(t *testing.T) {
+	good := writeFile(t, "good.ka", "karma x = 1;\nx + 1\n")
+	bad := writeFile(t, "bad.ka", "karma = 1;\n")
+	failing := writeFile(t, "failing.ka", "1 + true\n")
+
+	tests := []struct {
+		stdin    string
+		args     []string
+		code     int
+		inStderr string
+	}{
+		{"", []string{"run", good}, exitOK, ""},
+		{"", []string{"run", good, "-v", "extra"}, exitOK, ""},
+		{"", []string{"run", bad}, exitError, bad + ":1:7: error[E0200]: expected a name, found '='"},
+		{"", []string{"run", failing}, exitError, failing + ": type mismatch: INTEGER + BOOLEAN"},
+		{"", []string{"run", filepath.Join(t.TempDir(), "missing.ka")}, exitError, "no such file or directory"},
+		{"1 + 1", []string{"run", "-"}, exitOK, ""},
+		{"", []string{"run"}, exitUsage, "run needs a file"},
+		{"", []string{"check", good}, exitOK, ""},
+		{"", []string{"check", good, bad}, exitError, bad + ":1:7:"},
+		{"karma = 1;", []string{"check", "-"}, exitError, "<stdin>:1:7:"},
+		{"", []string{"check"}, exitUsage, "check needs at least one file"},
+		{"", []string{"lex", good}, exitOK, ""},
+		{"`open", []string{"lex", "-"}, exitError, "unterminated raw string"},
+		{"", []string{"lex", good, bad}, exitUsage, "lex needs exactly one file"},
+		{"", []string{"lex", "-bogus", good}, exitUsage, "flag provided but not defined: -bogus"},
+		{"", []string{"parse", good}, exitOK, ""},
+		{"", []string{"parse", bad}, exitError, bad + ":1:7:"},
+		{"", []string{"bogus"}, exitUsage, `unknown command "bogus"`},
+		{"", []string{"help"}, exitOK, ""},
+	}
+
+	for _, tt := range tests {
+		code, _, stderr := karma(tt.stdin, tt.args...)
+		if code != tt.code {
+			t.Errorf("karma %v: wrong exit status. want=%d, got=%d (stderr=%q)", tt.args, tt.code, code, stderr)
+		}
+		if tt.inStderr == "" && stderr != "" {
+			t.Errorf("karma %v: unexpected stderr %q", tt.args, stderr)
+		}
+		if !strings.Contains(stderr, tt.inStderr) {
+			t.Errorf("karma %v: stderr does not contain %q. got=%q", tt.args, tt.inStderr, stderr)
+		}
+	}
+}
+
+func TestLex(t *testing.T) {
+	_, stdout, _ := karma("karma x = 5;", "lex", "-")
+
+	expected := `1:1      KARMA      "karma"
+1:7      IDENT      "x"
+1:9      =          "="
+1:11     INT        "5"
+1:12     ;          ";"
+`
+	if stdout != expected {
+		t.Errorf("wrong output.\nexpected=%q\n     got=%q", expected, stdout)
+	}
+
+	_, stdout, _ = karma("x", "lex", "-json", "-")
+	var tokens []map[string]interface{}
+	if err := json.Unmarshal([]byte(stdout), &tokens); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, stdout)
+	}
+	if len(tokens) != 1 || tokens[0]["type"] != "IDENT" || tokens[0]["literal"] != "x" {
+		t.Errorf("wrong tokens. got=%v", tokens)
+	}
+}
+
+func TestParse(t *testing.T) {
+	_, stdout, _ := karma("karma x = -a;\nf()", "parse", "-")
+
+	expected := `Program
+  Statements:
+    - LetStatement 1:1
+      Name: Identifier 1:7
+        Value: "x"
+      Value: PrefixExpression 1:11
+        Operator: "-"
+        Right: Identifier 1:12
+          Value: "a"
+    - ExpressionStatement 2:1
+      Expression: CallExpression 2:2
+        Function: Identifier 2:1
+          Value: "f"
+        Arguments: []
+`
+	if stdout != expected {
+		t.Errorf("wrong output.\nexpected=%s\n     got=%s", expected, stdout)
+	}
+
+	_, stdout, _ = karma("return;", "parse", "-json", "-")
+	expected = `{
+  "type": "Program",
+  "statements": [
+    {
+      "type": "ReturnStatement",
+      "start": {
+        "offset": 0,
+        "line": 1,
+        "column": 1
+      },
+      "returnValue": null
+    }
+  ]
+}
+`
+	if stdout != expected {
+		t.Errorf("wrong JSON output.\nexpected=%s\n     got=%s", expected, stdout)
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	script := writeFile(t, "script.ka", `#!/usr/bin/env karma
+if (len(args()) != 2) { exit(2) }
+if (args()[0] == "fail") { exit(int_code) }
+exit(if (args()[1] == "ok") { 0 } else { 7 })
+`)
+
+	tests := []struct {
+		args []string
+		code int
+	}{
+		{[]string{"run", script, "a", "ok"}, 0},
+		{[]string{"run", script, "a", "b"}, 7},
+		{[]string{"run", script}, 2},
+		{[]string{"run", script, "fail", "x"}, exitError},
+		{[]string{script, "a", "ok"}, 0},
+		{[]string{script, "a", "b"}, 7},
+	}
+
+	for _, tt := range tests {
+		code, _, stderr := karma("", tt.args...)
+		if code != tt.code {
+			t.Errorf("karma %v: wrong exit status. want=%d, got=%d (stderr=%q)", tt.args, tt.code, code, stderr)
+		}
+	}
+}
+
+func TestRunErrorFilename(t *testing.T) {
+	code, _, stderr := karma("1 / 0", "run", "-")
+	if code != exitError {
+		t.Fatalf("wrong exit status. want=%d, got=%d (stderr=%q)", exitError, code, stderr)
+	}
+	if !strings.HasPrefix(stderr, stdinFilename+": ") {
+		t.Errorf("runtime error not reported against %s. got=%q", stdinFilename, stderr)
+	}
+}
+
+func TestRunPrints(t *testing.T) {
+	code, stdout, stderr := karma(`print("sum", 1 + 2); print(len([1, 2]))`, "run", "-")
+	if code != exitOK {
+		t.Fatalf("wrong exit status. want=%d, got=%d (stderr=%q)", exitOK, code, stderr)
+	}
+	if expected := "sum 3\n2\n"; stdout != expected {
+		t.Errorf("wrong output. want=%q, got=%q", expected, stdout)
+	}
+}
@@ -0,0 +1,92 @@
+package object
+
+import (
+	"io"
+	"os"
+	"sort"
+)
+
+// Config holds what programs see of the interpreter running them, through
+// builtins such as print and args. Every interpreter, such as each REPL
+// session, has its own.
+type Config struct {
+	Output io.Writer // where print writes
+	Args   []string  // the command-line arguments of a script
+}
+
+// Environment maps names to the values bound to them. Environments nest:
+// a function call runs in a new environment enclosed by the one the
+// function was defined in, and names not found locally are looked up in
+// the enclosing environments.
+type Environment struct {
+	store  map[string]Object
+	outer  *Environment
+	config *Config
+}
+
+// NewEnvironment creates an empty top-level environment whose programs
+// print to standard output and have no arguments.
+func NewEnvironment() *Environment {
+	return NewConfiguredEnvironment(&Config{Output: os.Stdout})
+}
+
+// NewConfiguredEnvironment creates an empty top-level environment whose
+// programs run with config.
+func NewConfiguredEnvironment(config *Config) *Environment {
+	return &Environment{store: make(map[string]Object), config: config}
+}
+
+// NewEnclosedEnvironment creates an empty environment nested inside outer.
+// It shares the configuration of outer.
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewConfiguredEnvironment(outer.config)
+	env.outer = outer
+	return env
+}
+
+// Config returns the configuration programs run with in e.
+func (e *Environment) Config() *Config {
+	return e.config
+}
+
+// Get returns the value bound to name in this environment or, failing
+// that, in the nearest enclosing environment that binds it.
+func (e *Environment) Get(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		obj, ok = e.outer.Get(name)
+	}
+	return obj, ok
+}
+
+// Set binds name to val in this environment, replacing any earlier binding
+// here and shadowing bindings in enclosing environments. It returns val.
+func (e *Environment) Set(name string, val Object) Object {
+	e.store[name] = val
+	return val
+}
+
+// Assign rebinds an existing name to val in the environment that binds it,
+// which may be an enclosing one. It reports false, and changes nothing, if
+// name is not bound anywhere.
+func (e *Environment) Assign(name string, val Object) bool {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return true
+	}
+	if e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+	return false
+}
+
+// Names returns the names bound in this environment, not counting
+// enclosing ones, in sorted order.
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
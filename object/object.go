@@ -0,0 +1,313 @@
+// Package object defines the values Karma programs compute with at runtime.
+//
+// Every value implements Object, which reports the value's type and renders
+// it for display. The evaluator produces and consumes these values; the REPL
+// prints them with Inspect.
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"karma/ast"
+	"strconv"
+	"strings"
+)
+
+// ObjectType names the runtime type of a value, as shown in error messages.
+type ObjectType string
+
+const (
+	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
+	STRING_OBJ       = "STRING"
+	BOOLEAN_OBJ      = "BOOLEAN"
+	ARRAY_OBJ        = "ARRAY"
+	HASH_OBJ         = "HASH"
+	RANGE_OBJ        = "RANGE"
+	NULL_OBJ         = "NULL"
+	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	ERROR_OBJ        = "ERROR"
+	FUNCTION_OBJ     = "FUNCTION"
+	BUILTIN_OBJ      = "BUILTIN"
+	EXIT_OBJ         = "EXIT"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
+)
+
+// Object is a Karma runtime value.
+type Object interface {
+	Type() ObjectType
+	Inspect() string
+}
+
+type Integer struct {
+	Value int64
+}
+
+func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
+func (i *Integer) Inspect() string  { return strconv.FormatInt(i.Value, 10) }
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
+// Inspect renders the float so that it never reads as an integer: 2.0
+// is shown as "2.0", not "2".
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eEIN") {
+		s += ".0"
+	}
+	return s
+}
+
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType { return STRING_OBJ }
+func (s *String) Inspect() string  { return strconv.Quote(s.Value) }
+
+type Boolean struct {
+	Value bool
+}
+
+func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
+func (b *Boolean) Inspect() string  { return strconv.FormatBool(b.Value) }
+
+// Null is the value of expressions that produce nothing, such as an if
+// without an else whose condition is false.
+type Null struct{}
+
+func (n *Null) Type() ObjectType { return NULL_OBJ }
+func (n *Null) Inspect() string  { return "null" }
+
+// The values of true, false and null never change, so they are shared.
+var (
+	NULL  = &Null{}
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+)
+
+// Text returns the text obj contributes to a string: the text of a string
+// itself, the Inspect form of every other value.
+func Text(obj Object) string {
+	if str, ok := obj.(*String); ok {
+		return str.Value
+	}
+	return obj.Inspect()
+}
+
+// Array is an ordered list of values.
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType { return ARRAY_OBJ }
+func (a *Array) Inspect() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, el := range a.Elements {
+		elements = append(elements, el.Inspect())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// HashKey identifies a hash key. Equal keys of the same type have equal
+// HashKeys, so a hash finds its entries by value: two separately created
+// strings "a" name the same entry.
+type HashKey struct {
+	Type  ObjectType
+	Value uint64 // integers and booleans
+	Text  string // strings
+}
+
+// Hashable is implemented by the values that can be used as hash keys.
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (s *String) HashKey() HashKey {
+	return HashKey{Type: s.Type(), Text: s.Value}
+}
+
+// HashPair is an entry of a Hash: the key as it was given, and its value.
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash maps keys to values. It remembers the order in which keys were
+// first added, and lists its pairs in that order.
+type Hash struct {
+	pairs map[HashKey]HashPair
+	keys  []HashKey
+}
+
+// NewHash returns an empty Hash.
+func NewHash() *Hash {
+	return &Hash{pairs: make(map[HashKey]HashPair)}
+}
+
+// Set binds key to value. A key that is already present keeps its place
+// in the order.
+func (h *Hash) Set(key Hashable, value Object) {
+	hk := key.HashKey()
+	if _, ok := h.pairs[hk]; !ok {
+		h.keys = append(h.keys, hk)
+	}
+	h.pairs[hk] = HashPair{Key: key, Value: value}
+}
+
+// Get returns the value bound to key, and whether there is one.
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	pair, ok := h.pairs[key.HashKey()]
+	return pair.Value, ok
+}
+
+// Len returns the number of pairs in h.
+func (h *Hash) Len() int {
+	return len(h.keys)
+}
+
+// Pairs returns the pairs of h in the order their keys were first added.
+func (h *Hash) Pairs() []HashPair {
+	pairs := make([]HashPair, len(h.keys))
+	for i, hk := range h.keys {
+		pairs[i] = h.pairs[hk]
+	}
+	return pairs
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range h.Pairs() {
+		pairs = append(pairs, pair.Key.Inspect()+": "+pair.Value.Inspect())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// Range is the integers from Start up to, but not including, End, as
+// written start..end.
+type Range struct {
+	Start int64
+	End   int64
+}
+
+func (r *Range) Type() ObjectType { return RANGE_OBJ }
+func (r *Range) Inspect() string  { return fmt.Sprintf("%d..%d", r.Start, r.End) }
+
+// ReturnValue wraps the value of a return statement while it unwinds
+// through enclosing blocks.
+type ReturnValue struct {
+	Value Object
+}
+
+func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
+func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
+
+// Error is a runtime error. Like ReturnValue it stops evaluation of the
+// enclosing blocks and becomes the result of the program.
+type Error struct {
+	Message string
+}
+
+func (e *Error) Type() ObjectType { return ERROR_OBJ }
+func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+
+// NewError creates an Error with a formatted message.
+func NewError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
+}
+
+// Exit is a request to end the program with a status code, made by the
+// exit builtin. Like Error it stops evaluation of the enclosing blocks and
+// becomes the result of the program.
+type Exit struct {
+	Code int
+}
+
+func (e *Exit) Type() ObjectType { return EXIT_OBJ }
+func (e *Exit) Inspect() string  { return fmt.Sprintf("exit(%d)", e.Code) }
+
+// Break and Continue are the signals of the break and continue statements.
+// Like ReturnValue they stop evaluation of the enclosing blocks, up to the
+// innermost loop.
+type (
+	Break    struct{}
+	Continue struct{}
+)
+
+func (b *Break) Type() ObjectType    { return BREAK_OBJ }
+func (b *Break) Inspect() string     { return "break" }
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+
+// Function is a function value. It keeps the environment it was defined
+// in, so the body can still see the bindings around its definition when
+// it is called later: functions are closures.
+type Function struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
+func (f *Function) Inspect() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range f.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("fun(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(f.Body.String())
+
+	return out.String()
+}
+
+// BuiltinFunction is the Go implementation of a builtin function. It is
+// called with the configuration of the environment the call is made in.
+type BuiltinFunction func(config *Config, args ...Object) Object
+
+// Builtin is a function provided by the interpreter rather than written in
+// Karma.
+type Builtin struct {
+	Name string
+	Fn   BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
+func (b *Builtin) Inspect() string  { return "builtin " + b.Name }
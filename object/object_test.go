@@ -0,0 +1,134 @@
+package object
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		obj      Object
+		expected string
+	}{
+		{&Integer{Value: -42}, "-42"},
+		{&Float{Value: 2}, "2.0"},
+		{&Float{Value: 3.25}, "3.25"},
+		{&Float{Value: 1e21}, "1e+21"},
+		{&String{Value: "say \"hi\""}, `"say \"hi\""`},
+		{&Boolean{Value: true}, "true"},
+		{&Null{}, "null"},
+		{&Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}, `[1, "a"]`},
+		{&Array{}, "[]"},
+		{&ReturnValue{Value: &Integer{Value: 1}}, "1"},
+		{NewError("bad %s", "thing"), "ERROR: bad thing"},
+		{&Exit{Code: 3}, "exit(3)"},
+		{&Range{Start: -1, End: 3}, "-1..3"},
+		{&Break{}, "break"},
+		{&Continue{}, "continue"},
+		{&Builtin{Name: "args"}, "builtin args"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.obj.Inspect(); got != tt.expected {
+			t.Errorf("%T.Inspect() wrong. expected=%q, got=%q", tt.obj, tt.expected, got)
+		}
+	}
+}
+
+func TestHashKey(t *testing.T) {
+	hello1 := &String{Value: "Hello World"}
+	hello2 := &String{Value: "Hello World"}
+	diff := &String{Value: "My name is johnny"}
+
+	if hello1.HashKey() != hello2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+	if hello1.HashKey() == diff.HashKey() {
+		t.Errorf("strings with different content have same hash keys")
+	}
+
+	one := &Integer{Value: 1}
+	if one.HashKey() == (&Boolean{Value: true}).HashKey() {
+		t.Errorf("1 and true have the same hash key")
+	}
+	if one.HashKey() == (&String{Value: "1"}).HashKey() {
+		t.Errorf(`1 and "1" have the same hash key`)
+	}
+}
+
+func TestHashOrder(t *testing.T) {
+	h := NewHash()
+	h.Set(&String{Value: "b"}, &Integer{Value: 1})
+	h.Set(&Integer{Value: 2}, &Boolean{Value: true})
+	h.Set(&String{Value: "a"}, &Null{})
+	h.Set(&String{Value: "b"}, &Integer{Value: 3})
+
+	expected := `{"b": 3, 2: true, "a": null}`
+	if got := h.Inspect(); got != expected {
+		t.Errorf("Inspect() wrong. expected=%q, got=%q", expected, got)
+	}
+	if h.Len() != 3 {
+		t.Errorf("Len() wrong. expected=3, got=%d", h.Len())
+	}
+	if _, ok := h.Get(&String{Value: "c"}); ok {
+		t.Errorf("Get found a key that was never set")
+	}
+}
+
+func TestEnvironmentAssign(t *testing.T) {
+	env := NewEnvironment()
+
+	if env.Assign("x", &Integer{Value: 1}) {
+		t.Fatalf("Assign succeeded for an unbound name")
+	}
+	if _, ok := env.Get("x"); ok {
+		t.Fatalf("Assign bound an unbound name")
+	}
+
+	env.Set("x", &Integer{Value: 1})
+	if !env.Assign("x", &Integer{Value: 2}) {
+		t.Fatalf("Assign failed for a bound name")
+	}
+	if val, _ := env.Get("x"); val.(*Integer).Value != 2 {
+		t.Errorf("x has wrong value. got=%s", val.Inspect())
+	}
+}
+
+func TestEnclosedEnvironment(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("x", &Integer{Value: 1})
+	outer.Set("y", &Integer{Value: 2})
+
+	inner := NewEnclosedEnvironment(outer)
+	inner.Set("y", &Integer{Value: 20})
+
+	if val, ok := inner.Get("x"); !ok || val.(*Integer).Value != 1 {
+		t.Errorf("inner.Get(x) did not find the outer binding. got=%v", val)
+	}
+	if val, _ := inner.Get("y"); val.(*Integer).Value != 20 {
+		t.Errorf("inner.Get(y) did not find the shadowing binding. got=%s", val.Inspect())
+	}
+
+	if !inner.Assign("x", &Integer{Value: 10}) {
+		t.Fatalf("inner.Assign(x) failed")
+	}
+	if val, _ := outer.Get("x"); val.(*Integer).Value != 10 {
+		t.Errorf("inner.Assign(x) did not update the outer binding. got=%s", val.Inspect())
+	}
+	if val, _ := outer.Get("y"); val.(*Integer).Value != 2 {
+		t.Errorf("shadowed outer y changed. got=%s", val.Inspect())
+	}
+}
+
+func TestEnvironmentNames(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("z", &Null{})
+	inner := NewEnclosedEnvironment(outer)
+	inner.Set("b", &Null{})
+	inner.Set("a", &Null{})
+
+	got := strings.Join(inner.Names(), ",")
+	if got != "a,b" {
+		t.Errorf("wrong names. want=%q, got=%q", "a,b", got)
+	}
+}
@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// numberBases maps the letter after a leading 0 to the base and the name
+// of the integer literal it introduces.
+var numberBases = map[byte]struct {
+	base int
+	name string
+}{
+	'x': {16, "hexadecimal"},
+	'o': {8, "octal"},
+	'b': {2, "binary"},
+}
+
+// numberLiteralError checks the text of an INT or FLOAT token against
+// Karma's numeric literal grammar and describes the first problem found.
+// It returns "" for a well-formed literal.
+func numberLiteralError(lit string) string {
+	if len(lit) >= 2 && lit[0] == '0' {
+		if b, ok := numberBases[lit[1]|0x20]; ok {
+			digits := lit[2:]
+			if strings.Trim(digits, "_") == "" {
+				return fmt.Sprintf("%s literal %q has no digits", b.name, lit)
+			}
+			for _, ch := range digits {
+				if ch != '_' && (ch >= utf8.RuneSelf || digitValue(byte(ch)) >= b.base) {
+					return fmt.Sprintf("invalid digit %q in %s literal %q", ch, b.name, lit)
+				}
+			}
+			return separatorError(lit, 2)
+		}
+	}
+
+	if ch := invalidDecimalChar(lit); ch != 0 {
+		return fmt.Sprintf("invalid digit %q in %q", ch, lit)
+	}
+
+	mantissa, exponent := lit, ""
+	if i := strings.IndexAny(lit, "eE"); i >= 0 {
+		mantissa, exponent = lit[:i], lit[i+1:]
+		if strings.Trim(strings.TrimLeft(exponent, "+-"), "_") == "" {
+			return fmt.Sprintf("exponent has no digits in %q", lit)
+		}
+	}
+
+	if exponent == "" && !strings.Contains(mantissa, ".") &&
+		len(mantissa) > 1 && mantissa[0] == '0' {
+		return leadingZeroError(lit, strings.TrimLeft(mantissa, "0_"))
+	}
+
+	return separatorError(lit, 0)
+}
+
+// invalidDecimalChar returns the first character of the decimal literal
+// lit that cannot be part of one, such as the a in "9a", or 0 if there is
+// none.
+func invalidDecimalChar(lit string) rune {
+	var prev rune
+	exponent := false
+	for _, ch := range lit {
+		switch {
+		case '0' <= ch && ch <= '9', ch == '_', ch == '.':
+		case (ch == 'e' || ch == 'E') && !exponent:
+			exponent = true
+		case (ch == '+' || ch == '-') && (prev == 'e' || prev == 'E'):
+		default:
+			return ch
+		}
+		prev = ch
+	}
+	return 0
+}
+
+// leadingZeroError describes the leading zeros of the integer literal lit,
+// whose digits after them are rest. It suggests an octal literal only when
+// rest could be one, and the number without its zeros otherwise.
+func leadingZeroError(lit, rest string) string {
+	if rest == "" {
+		return fmt.Sprintf("leading zeros are not allowed in %q; write 0", lit)
+	}
+	for i := 0; i < len(rest); i++ {
+		if rest[i] != '_' && digitValue(rest[i]) >= 8 {
+			return fmt.Sprintf("leading zeros are not allowed in %q; write %s", lit, rest)
+		}
+	}
+	return fmt.Sprintf("leading zeros are not allowed in %q; use 0o%s for an octal number", lit, rest)
+}
+
+// separatorError reports a '_' in lit that does not sit between two digits.
+// A '_' directly after a base prefix of length prefix is allowed.
+func separatorError(lit string, prefix int) string {
+	for i := 0; i < len(lit); i++ {
+		if lit[i] != '_' {
+			continue
+		}
+		prevOK := i == prefix && prefix > 0 || i > 0 && digitValue(lit[i-1]) < 16 && !isExponentChar(lit, i-1, prefix)
+		nextOK := i+1 < len(lit) && digitValue(lit[i+1]) < 16 && !isExponentChar(lit, i+1, prefix)
+		if !prevOK || !nextOK {
+			return fmt.Sprintf("'_' must separate successive digits in %q", lit)
+		}
+	}
+	return ""
+}
+
+// isExponentChar reports whether lit[i] is the exponent marker of a decimal
+// literal rather than a hexadecimal digit.
+func isExponentChar(lit string, i, prefix int) bool {
+	return prefix == 0 && (lit[i] == 'e' || lit[i] == 'E')
+}
+
+// digitValue returns the value of the hexadecimal digit ch, or 16 if ch is
+// not a digit.
+func digitValue(ch byte) int {
+	switch {
+	case '0' <= ch && ch <= '9':
+		return int(ch - '0')
+	case 'a' <= ch && ch <= 'f':
+		return int(ch - 'a' + 10)
+	case 'A' <= ch && ch <= 'F':
+		return int(ch - 'A' + 10)
+	}
+	return 16
+}
@@ -7,61 +7,141 @@
 package parser
 
 import (
-	"fmt"
 	"karma/ast"
+	"karma/diagnostic"
 	"karma/lexer"
 	"karma/token"
 	"strconv"
 )
 
-const (	
+const (
 	_ int = iota
 	LOWEST
-	EQUALS // ==
+	ASSIGN      // = += -= *= /=
+	PIPE        // |>
+	OR          // ||
+	AND         // &&
+	EQUALS      // ==
 	LESSGREATER // > or <
-	SUM // +
-	PRODUCT // *
-	PREFIX // -X or !X
-	CALL // myFun(X)
+	RANGE       // ..
+	SUM         // +
+	PRODUCT     // * / %
+	PREFIX      // -X or !X
+	CALL        // myFun(X)
+	INDEX       // array[index]
 )
-var precedences = map[token.TokenType]int {
-	token.EQ: EQUALS,
-	token.NOT_EQ: EQUALS,
-	token.LT: LESSGREATER,
-	token.GT: LESSGREATER,
-	token.PLUS: SUM,
-	token.MINUS: SUM,
-	token.SLASH: PRODUCT,
-	token.ASTERISK: PRODUCT,
+
+// precedences lists the binding power of every infix operator. The arrows
+// -> and => are lexed but have no entry: they are not infix operators.
+var precedences = map[token.TokenType]int{
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.PIPE:            PIPE,
+	token.OR:              OR,
+	token.AND:             AND,
+	token.EQ:              EQUALS,
+	token.NOT_EQ:          EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.LT_EQ:           LESSGREATER,
+	token.GT_EQ:           LESSGREATER,
+	token.DOT_DOT:         RANGE,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.PERCENT:         PRODUCT,
+	token.LPAREN:          CALL,
+	token.LBRACKET:        INDEX,
+}
+
+// Diagnostic codes. Codes starting with E01 are for problems found by the
+// lexer, those starting with E02 for problems found by the parser.
+const (
+	codeInvalidInput    = "E0100" // a character or escape sequence that means nothing
+	codeUnterminated    = "E0101" // input ended inside a string or comment
+	codeUnexpectedToken = "E0200"
+	codeMissingOperand  = "E0201" // expected an expression
+	codeUnclosed        = "E0202" // input ended inside brackets
+	codeBadList         = "E0203" // a list with a missing or unseparated element
+	codeBadNumber       = "E0204"
+	codeBadAssignment   = "E0205"
+	codeDuplicateParam  = "E0206"
+	codeTooManyErrors   = "E0207"
+	codeOutsideLoop     = "E0208" // break or continue outside a loop
+)
+
+// maxErrors is the number of errors after which the parser gives up on a
+// program. Past the first few, errors are rarely useful.
+const maxErrors = 10
+
+// statementKeywords holds the keywords that begin a statement. Recovery
+// from a syntax error resumes at one of them.
+var statementKeywords = map[token.TokenType]bool{
+	token.KARMA:    true,
+	token.RETURN:   true,
+	token.WHILE:    true,
+	token.FOR:      true,
+	token.BREAK:    true,
+	token.CONTINUE: true,
 }
 
 // Parser represents the syntactic analyzer for the Karma language.
 type Parser struct {
-	l *lexer.Lexer
-	curToken token.Token
+	l         *lexer.Lexer
+	curToken  token.Token
 	peekToken token.Token
 
-	errors []string
+	// depth is the number of braces opened, and not yet closed, before
+	// curToken.
+	depth int
+
+	// loops is the number of loops around curToken within the innermost
+	// function, where break and continue are allowed when it is not 0.
+	loops int
+
+	diagnostics []diagnostic.Diagnostic
+	// lexerErrors is the number of lexer diagnostics already copied
+	// into diagnostics.
+	lexerErrors int
+
+	// resume is set when recovery from a syntax error stopped on the
+	// token that begins the next statement, or closes the enclosing
+	// block, rather than on the last token of the broken statement.
+	resume bool
 
 	prefixParseFns map[token.TokenType]prefixParseFn
-	infixParseFns map[token.TokenType]infixParseFn
+	infixParseFns  map[token.TokenType]infixParseFn
 }
 
 // New creates and returns a new Parser instance initialized with a given lexer.
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser {
-		l : l,
-		errors: []string{},
+	p := &Parser{
+		l:           l,
+		diagnostics: []diagnostic.Diagnostic{},
 	}
-	
+
 	p.nextToken()
 	p.nextToken()
-	
+
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.STRING_HEAD, p.parseInterpolatedString)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.TRUE, p.parseBoolean)
+	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
+	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -72,33 +152,64 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.DOT_DOT, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
+	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
 
 	return p
 }
 
 type (
 	prefixParseFn func() ast.Expression
-	infixParseFn func(ast.Expression) ast.Expression
+	infixParseFn  func(ast.Expression) ast.Expression
 )
 
-// nextToken advances the parser’s tokens by one position.
+// nextToken advances the parser’s tokens by one position. Any diagnostics
+// the lexer reported while scanning the new token are added to the parser's
+// diagnostics, so they appear in source order alongside syntax errors.
 func (p *Parser) nextToken() {
+	switch p.curToken.Type {
+	case token.LBRACE:
+		p.depth++
+	case token.RBRACE:
+		if p.depth > 0 { // a stray } closes nothing
+			p.depth--
+		}
+	}
+
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	lexerErrors := p.l.Errors()
+	for _, err := range lexerErrors[p.lexerErrors:] {
+		p.report(lexerDiagnostic(err))
+	}
+	p.lexerErrors = len(lexerErrors)
 }
 
 // curTokenIs checks whether the current token’s type matches the given type.
-func(p *Parser) curTokenIs(t token.TokenType) bool {
+func (p *Parser) curTokenIs(t token.TokenType) bool {
 	return p.curToken.Type == t
 }
 
 // peekTokenIs checks whether the next token’s type matches the given type.
-func(p *Parser) peekTokenIS(t token.TokenType) bool {
+func (p *Parser) peekTokenIS(t token.TokenType) bool {
 	return p.peekToken.Type == t
 }
 
 // expectedPeek checks whether the next token matches the expected type.
-func(p *Parser) expectedPeek(t token.TokenType) bool {
+func (p *Parser) expectedPeek(t token.TokenType) bool {
 	if p.peekTokenIS(t) {
 		p.nextToken()
 		return true
@@ -109,9 +220,13 @@ func(p *Parser) expectedPeek(t token.TokenType) bool {
 }
 
 // parseLetStatement parses a `let` statement of the form:
+//
 //	karma <identifier> = <expression>;
-func(p *Parser) parseLetStatement() *ast.LetStatement{
+//
+// A value that cannot be parsed becomes an ast.BadExpression.
+func (p *Parser) parseLetStatement() *ast.LetStatement {
 	stmt := &ast.LetStatement{Token: p.curToken}
+	depth := p.depth
 
 	if !p.expectedPeek(token.IDENT) {
 		return nil
@@ -123,33 +238,194 @@ func(p *Parser) parseLetStatement() *ast.LetStatement{
 		return nil
 	}
 
-	// we skipped the expression part for now
-	if !p.curTokenIs(token.SEMICOLON) {
-		p.nextToken()
-	}
+	p.nextToken()
 
+	stmt.Value = p.parseValue(stmt.Token, depth)
 	return stmt
 }
 
+// parseValue parses the expression that ends the statement beginning with
+// start, at the given brace depth, along with its semicolon. When the
+// expression cannot be parsed, the rest of the statement is skipped and
+// an ast.BadExpression returned in its place.
+func (p *Parser) parseValue(start token.Token, depth int) ast.Expression {
+	first := p.curToken
+
+	value := p.parseExpression(LOWEST)
+	if value == nil {
+		end := p.synchronize(start, depth)
+		return &ast.BadExpression{Token: first, End: end}
+	}
+
+	if !p.endStatement() {
+		p.synchronize(start, depth)
+	}
+	return value
+}
+
 // parseReturnStatement parses a return statement of the form:
+//
 //	return <expression>;
+//
+// The expression may be left out, as in `return;`. A value that cannot be
+// parsed becomes an ast.BadExpression.
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
+	depth := p.depth
+
+	if p.atStatementEnd() {
+		p.endStatement()
+		return stmt
+	}
+
+	p.nextToken()
+
+	stmt.ReturnValue = p.parseValue(stmt.Token, depth)
+	return stmt
+}
+
+// parseWhileStatement parses a loop of the form:
+//
+//	while (<condition>) { <body> }
+func (p *Parser) parseWhileStatement() *ast.WhileStatement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectedPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+	if stmt.Condition == nil {
+		return nil
+	}
+
+	if !p.expectedPeek(token.RPAREN) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+	if stmt.Body == nil {
+		return nil
+	}
+
+	return stmt
+}
+
+// parseForStatement parses a loop of the form:
+//
+//	for (<identifier> in <expression>) { <body> }
+func (p *Parser) parseForStatement() *ast.ForStatement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if !p.expectedPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectedPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectedPeek(token.IN) {
+		return nil
+	}
 
 	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+	if stmt.Iterable == nil {
+		return nil
+	}
 
-	//TODO: parse the expression
+	if !p.expectedPeek(token.RPAREN) {
+		return nil
+	}
 
-	for !p.curTokenIs(token.SEMICOLON) {
+	stmt.Body = p.parseLoopBody()
+	if stmt.Body == nil {
+		return nil
+	}
+
+	return stmt
+}
+
+// parseLoopBody parses the block that follows the head of a loop, inside
+// which break and continue are allowed, along with an optional semicolon
+// after it.
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	if !p.expectedPeek(token.LBRACE) {
+		return nil
+	}
+
+	p.loops++
+	body := p.parseBlockStatement()
+	p.loops--
+	if body == nil {
+		return nil
+	}
+
+	if p.peekTokenIS(token.SEMICOLON) {
 		p.nextToken()
 	}
+	return body
+}
 
+// parseBreakStatement parses `break;`, which must be inside a loop.
+func (p *Parser) parseBreakStatement() *ast.BreakStatement {
+	stmt := &ast.BreakStatement{Token: p.curToken}
+	if !p.checkInLoop() || !p.endStatement() {
+		return nil
+	}
 	return stmt
 }
 
+// parseContinueStatement parses `continue;`, which must be inside a loop.
+func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
+	stmt := &ast.ContinueStatement{Token: p.curToken}
+	if !p.checkInLoop() || !p.endStatement() {
+		return nil
+	}
+	return stmt
+}
+
+// checkInLoop reports an error unless the current token, a break or a
+// continue, is inside a loop of the function it appears in.
+func (p *Parser) checkInLoop() bool {
+	if p.loops > 0 {
+		return true
+	}
+	p.report(diagnostic.Errorf(diagnostic.TokenSpan(p.curToken), codeOutsideLoop,
+		"%s outside a loop", p.curToken.Literal))
+	return false
+}
+
+// atStatementEnd reports whether the next token may end a statement.
+func (p *Parser) atStatementEnd() bool {
+	return p.peekTokenIS(token.SEMICOLON) || p.peekTokenIS(token.RBRACE) || p.peekTokenIS(token.EOF)
+}
+
+// endStatement consumes the semicolon that ends a statement. The semicolon
+// may be left out before the closing brace of a block or the end of input;
+// anywhere else its absence is reported as an error.
+func (p *Parser) endStatement() bool {
+	if p.peekTokenIS(token.SEMICOLON) {
+		p.nextToken()
+		return true
+	}
+	if p.peekTokenIS(token.RBRACE) || p.peekTokenIS(token.EOF) {
+		return true
+	}
+	p.peekError(token.SEMICOLON)
+	return false
+}
+
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	if t == token.ILLEGAL {
+		// the lexer has already reported why the token is illegal
+		return
+	}
+	p.report(diagnostic.Errorf(diagnostic.TokenSpan(p.curToken), codeMissingOperand,
+		"expected an expression, found %s", p.curToken.Describe()))
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
@@ -161,29 +437,38 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	}
 
 	leftExp := prefix()
+	if leftExp == nil {
+		return nil
+	}
 
 	for !p.peekTokenIS(token.SEMICOLON) && precedence < p.peekPrecedence() {
 		infix := p.infixParseFns[p.peekToken.Type]
 		if infix == nil {
 			return leftExp
 		}
-		
+
 		p.nextToken()
 
 		leftExp = infix(leftExp)
+		if leftExp == nil {
+			return nil
+		}
 	}
 	return leftExp
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
 	expression := &ast.PrefixExpression{
-		Token: p.curToken,
+		Token:    p.curToken,
 		Operator: p.curToken.Literal,
 	}
 
 	p.nextToken()
 
 	expression.Right = p.parseExpression(PREFIX)
+	if expression.Right == nil {
+		return nil
+	}
 
 	return expression
 }
@@ -192,6 +477,9 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
 	stmt.Expression = p.parseExpression(LOWEST)
+	if stmt.Expression == nil {
+		return nil
+	}
 
 	if p.peekTokenIS(token.SEMICOLON) {
 		p.nextToken()
@@ -201,44 +489,164 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 }
 
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
-	expression := &ast.InfixExpression {
-		Token: p.curToken,
+	if left == nil {
+		return nil
+	}
+
+	expression := &ast.InfixExpression{
+		Token:    p.curToken,
 		Operator: p.curToken.Literal,
-		Left: left,
+		Left:     left,
 	}
 
 	precedence := p.curPrecedence()
 	p.nextToken()
 	expression.Right = p.parseExpression(precedence)
+	if expression.Right == nil {
+		return nil
+	}
+
+	return expression
+}
+
+// parseAssignExpression parses an assignment to an existing binding:
+//
+//	<identifier> = <expression>
+//	<identifier> += <expression>	(also -=, *= and /=)
+//
+// Assignment is right-associative, so a = b = 1 assigns 1 to both.
+func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
+	if left == nil {
+		return nil
+	}
+
+	name, ok := left.(*ast.Identifier)
+	if !ok {
+		d := diagnostic.Errorf(diagnostic.TokenSpan(p.curToken), codeBadAssignment, "cannot assign to %s", left.String())
+		p.report(d.WithNote("only a name can be assigned to, as in x = 1"))
+		return nil
+	}
+
+	expression := &ast.AssignExpression{
+		Token:    p.curToken,
+		Name:     name,
+		Operator: p.curToken.Literal,
+	}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(ASSIGN - 1)
+	if expression.Value == nil {
+		return nil
+	}
 
 	return expression
 }
 
 // parseStatement determines which type of statement the current token represents
-// and delegates to the appropriate parsing function.
-func (p *Parser) parseStatement() ast.Statement{
+// and delegates to the appropriate parsing function. A statement that cannot
+// be parsed is skipped and returned as an ast.BadStatement.
+func (p *Parser) parseStatement() ast.Statement {
+	start, depth := p.curToken, p.depth
+
 	switch p.curToken.Type {
 	case token.KARMA:
-		return p.parseLetStatement()
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
 	case token.RETURN:
-		return p.parseReturnStatement()
-	default: 
-		return p.parseExpressionStatement()
+		if stmt := p.parseReturnStatement(); stmt != nil {
+			return stmt
+		}
+	case token.WHILE:
+		if stmt := p.parseWhileStatement(); stmt != nil {
+			return stmt
+		}
+	case token.FOR:
+		if stmt := p.parseForStatement(); stmt != nil {
+			return stmt
+		}
+	case token.BREAK:
+		if stmt := p.parseBreakStatement(); stmt != nil {
+			return stmt
+		}
+	case token.CONTINUE:
+		if stmt := p.parseContinueStatement(); stmt != nil {
+			return stmt
+		}
+	default:
+		if stmt := p.parseExpressionStatement(); stmt != nil {
+			return stmt
+		}
 	}
+
+	end := p.synchronize(start, depth)
+	return &ast.BadStatement{Token: start, End: end}
+}
+
+// synchronize skips the rest of the statement that began with start, at
+// the given brace depth, after a syntax error in it. Parsing then resumes
+// with the next statement rather than reporting an error for every token
+// of the broken one. It returns where the skipped source ends.
+//
+// Skipping stops at the statement's semicolon, or before a keyword that
+// begins a statement, a } that closes the enclosing block, or the end of
+// input. Braces opened within the statement are skipped as a whole.
+func (p *Parser) synchronize(start token.Token, depth int) token.Position {
+	for !p.curTokenIs(token.EOF) {
+		if p.depth == depth && p.curToken.Start.Offset != start.Start.Offset {
+			if statementKeywords[p.curToken.Type] || p.curTokenIs(token.RBRACE) && depth > 0 {
+				p.resume = true
+				return p.curToken.Start
+			}
+		}
+		if p.depth == depth && !p.curTokenIs(token.LBRACE) {
+			if p.curTokenIs(token.SEMICOLON) {
+				break
+			}
+			if statementKeywords[p.peekToken.Type] || p.peekTokenIS(token.RBRACE) || p.peekTokenIS(token.EOF) {
+				break
+			}
+		}
+		p.nextToken()
+	}
+	return p.curToken.End
+}
+
+// nextStatement moves from the last token of a statement to the first
+// token of the next one.
+func (p *Parser) nextStatement() {
+	if p.resume {
+		p.resume = false
+		return
+	}
+	p.nextToken()
+}
+
+// tooManyErrors reports whether the parser has given up on the program.
+func (p *Parser) tooManyErrors() bool {
+	return len(p.diagnostics) >= maxErrors
 }
 
 // ParseProgram parses a complete Karma program.
+//
+// Parsing carries on after a syntax error, so the program returned is
+// complete apart from ast.BadStatement and ast.BadExpression nodes where
+// the errors are. After maxErrors errors the parser gives up and returns
+// the statements parsed so far.
 func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
 
 	for !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
-
-		if stmt != nil {
-			program.Statements = append(program.Statements, stmt)
+		if p.tooManyErrors() {
+			d := diagnostic.Errorf(diagnostic.TokenSpan(p.curToken), codeTooManyErrors, "too many errors")
+			p.diagnostics = append(p.diagnostics[:maxErrors], d.WithNote("fix the errors above and try again"))
+			break
 		}
-		p.nextToken()
+
+		stmt := p.parseStatement()
+		program.Statements = append(program.Statements, stmt)
+		p.nextStatement()
 	}
 	return program
 }
@@ -250,17 +658,438 @@ func (p *Parser) parseIdentifier() ast.Expression {
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
+	if msg := numberLiteralError(p.curToken.Literal); msg != "" {
+		p.report(diagnostic.Errorf(diagnostic.TokenSpan(p.curToken), codeBadNumber, "%s", msg))
+		return nil
+	}
+
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.report(diagnostic.Errorf(diagnostic.TokenSpan(p.curToken), codeBadNumber,
+			"could not parse %q as integer", p.curToken.Literal))
 		return nil
-	}	
-	
+	}
+
 	lit.Value = value
 	return lit
 }
 
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	if msg := numberLiteralError(p.curToken.Literal); msg != "" {
+		p.report(diagnostic.Errorf(diagnostic.TokenSpan(p.curToken), codeBadNumber, "%s", msg))
+		return nil
+	}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.report(diagnostic.Errorf(diagnostic.TokenSpan(p.curToken), codeBadNumber,
+			"could not parse %q as float", p.curToken.Literal))
+		return nil
+	}
+
+	lit.Value = value
+	return lit
+}
+
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+// parseInterpolatedString parses the tokens of an interpolated string,
+// starting at its STRING_HEAD, into a concatenation of the literal text
+// segments and the embedded expressions:
+//
+//	"a ${x} b" => ("a " + x + " b")
+//
+// Empty text segments are left out.
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	exp := &ast.ConcatExpression{Token: p.curToken}
+	exp.Parts = p.appendStringSegment(exp.Parts)
+
+	for {
+		p.nextToken()
+
+		part := p.parseExpression(LOWEST)
+		if part == nil {
+			return nil
+		}
+		exp.Parts = append(exp.Parts, part)
+
+		if p.peekTokenIS(token.STRING_TAIL) {
+			p.nextToken()
+			exp.Parts = p.appendStringSegment(exp.Parts)
+			return exp
+		}
+		if !p.expectedPeek(token.STRING_MIDDLE) {
+			return nil
+		}
+		exp.Parts = p.appendStringSegment(exp.Parts)
+	}
+}
+
+// appendStringSegment appends the text of the current string segment token
+// to parts, unless it is empty.
+func (p *Parser) appendStringSegment(parts []ast.Expression) []ast.Expression {
+	if p.curToken.Literal == "" {
+		return parts
+	}
+	return append(parts, &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal})
+}
+
+func (p *Parser) parseBoolean() ast.Expression {
+	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
+}
+
+// parseGroupedExpression parses an expression wrapped in parentheses,
+// which resets the precedence: (a + b) * c.
+func (p *Parser) parseGroupedExpression() ast.Expression {
+	p.nextToken()
+
+	exp := p.parseExpression(LOWEST)
+	if exp == nil {
+		return nil
+	}
+
+	if !p.expectedPeek(token.RPAREN) {
+		return nil
+	}
+
+	return exp
+}
+
+// parseIfExpression parses a conditional of the form:
+//
+//	if (<condition>) { <consequence> } else { <alternative> }
+//
+// The else branch is optional and may itself be another if expression.
+func (p *Parser) parseIfExpression() ast.Expression {
+	expression := &ast.IfExpression{Token: p.curToken}
+
+	if !p.expectedPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	expression.Condition = p.parseExpression(LOWEST)
+	if expression.Condition == nil {
+		return nil
+	}
+
+	if !p.expectedPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectedPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Consequence = p.parseBlockStatement()
+	if expression.Consequence == nil {
+		return nil
+	}
+
+	if p.peekTokenIS(token.ELSE) {
+		p.nextToken()
+
+		if p.peekTokenIS(token.IF) {
+			p.nextToken()
+			expression.Alternative = &ast.BlockStatement{Token: p.curToken}
+			nested := p.parseIfExpression()
+			if nested == nil {
+				return nil
+			}
+			expression.Alternative.Statements = []ast.Statement{
+				&ast.ExpressionStatement{Token: expression.Alternative.Token, Expression: nested},
+			}
+			return expression
+		}
+
+		if !p.expectedPeek(token.LBRACE) {
+			return nil
+		}
+
+		expression.Alternative = p.parseBlockStatement()
+		if expression.Alternative == nil {
+			return nil
+		}
+	}
+
+	return expression
+}
+
+// parseBlockStatement parses the statements between the current { and its
+// matching }.
+func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	block := &ast.BlockStatement{Token: p.curToken}
+	block.Statements = []ast.Statement{}
+
+	p.nextToken()
+
+	for !p.curTokenIs(token.RBRACE) {
+		if p.curTokenIs(token.EOF) {
+			d := diagnostic.Errorf(diagnostic.TokenSpan(p.curToken), codeUnclosed,
+				"expected '}' to close the block, found the end of input")
+			p.report(d.WithLabel(diagnostic.TokenSpan(block.Token), "block opened here"))
+			return nil
+		}
+		if p.tooManyErrors() {
+			return nil
+		}
+
+		block.Statements = append(block.Statements, p.parseStatement())
+		p.nextStatement()
+	}
+
+	return block
+}
+
+// parseFunctionLiteral parses a function literal of the form:
+//
+//	fun(<parameter>, <parameter>, ...) { <body> }
+func (p *Parser) parseFunctionLiteral() ast.Expression {
+	lit := &ast.FunctionLiteral{Token: p.curToken}
+
+	if !p.expectedPeek(token.LPAREN) {
+		return nil
+	}
+
+	lit.Parameters = p.parseFunctionParameters()
+	if lit.Parameters == nil {
+		return nil
+	}
+
+	if !p.expectedPeek(token.LBRACE) {
+		return nil
+	}
+
+	// break and continue cannot leave the function for a loop around it
+	loops := p.loops
+	p.loops = 0
+	lit.Body = p.parseBlockStatement()
+	p.loops = loops
+	if lit.Body == nil {
+		return nil
+	}
+
+	return lit
+}
+
+// parseFunctionParameters parses a comma-separated list of parameter names
+// up to the closing parenthesis. A trailing comma is allowed. It returns nil
+// after reporting an error.
+func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+	identifiers := []*ast.Identifier{}
+	seen := map[string]*ast.Identifier{}
+
+	for !p.peekTokenIS(token.RPAREN) {
+		if !p.expectedPeek(token.IDENT) {
+			return nil
+		}
+
+		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if first, ok := seen[ident.Value]; ok {
+			d := diagnostic.Errorf(diagnostic.TokenSpan(p.curToken), codeDuplicateParam, "duplicate parameter %s", ident.Value)
+			p.report(d.WithLabel(diagnostic.TokenSpan(first.Token), "first declared here"))
+			return nil
+		}
+		seen[ident.Value] = ident
+		identifiers = append(identifiers, ident)
+
+		if !p.peekTokenIS(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectedPeek(token.RPAREN) {
+		return nil
+	}
+
+	return identifiers
+}
+
+// parseCallExpression parses the argument list of a call whose function
+// expression has already been parsed:
+//
+//	<expression>(<expression>, <expression>, ...)
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	if function == nil {
+		return nil
+	}
+
+	exp := &ast.CallExpression{Token: p.curToken, Function: function}
+
+	exp.Arguments = p.parseExpressionList(token.RPAREN, "argument")
+	if exp.Arguments == nil {
+		return nil
+	}
+
+	return exp
+}
+
+// parseArrayLiteral parses a list of elements in brackets:
+//
+//	[<expression>, <expression>, ...]
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+
+	array.Elements = p.parseExpressionList(token.RBRACKET, "element")
+	if array.Elements == nil {
+		return nil
+	}
+
+	return array
+}
+
+// parseHashLiteral parses a list of key-value pairs in braces:
+//
+//	{<expression>: <expression>, <expression>: <expression>, ...}
+//
+// A block never begins an expression: the parser reads the body of an if
+// or a function as a block directly, so a { found where an expression is
+// expected always opens a hash. A trailing comma is allowed.
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken, Pairs: []*ast.HashPair{}}
+
+	for !p.peekTokenIS(token.RBRACE) {
+		if p.peekTokenIS(token.EOF) {
+			break
+		}
+
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+		if key == nil {
+			return nil
+		}
+
+		if !p.expectedPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		if value == nil {
+			return nil
+		}
+
+		hash.Pairs = append(hash.Pairs, &ast.HashPair{Key: key, Value: value})
+
+		if !p.peekTokenIS(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	switch {
+	case p.peekTokenIS(token.RBRACE):
+		p.nextToken()
+		return hash
+	case p.peekTokenIS(token.EOF):
+		d := diagnostic.Errorf(diagnostic.TokenSpan(p.peekToken), codeUnclosed,
+			"expected '}' to close the hash, found the end of input")
+		p.report(d.WithLabel(diagnostic.TokenSpan(hash.Token), "hash opened here"))
+	case !p.peekTokenIS(token.ILLEGAL):
+		p.report(diagnostic.Errorf(diagnostic.TokenSpan(p.peekToken), codeBadList,
+			"expected ',' or '}' after hash entry, found %s", p.peekToken.Describe()))
+	}
+	return nil
+}
+
+// parseIndexExpression parses an index or a slice of an expression that
+// has already been parsed:
+//
+//	<expression>[<index>]
+//	<expression>[<low>:<high>]
+//
+// Either bound of a slice may be left out, as in xs[1:] or xs[:].
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	if left == nil {
+		return nil
+	}
+
+	open := p.curToken
+
+	var index ast.Expression
+	if !p.peekTokenIS(token.COLON) {
+		p.nextToken()
+		index = p.parseExpression(LOWEST)
+		if index == nil {
+			return nil
+		}
+		if !p.peekTokenIS(token.COLON) {
+			if !p.expectedPeek(token.RBRACKET) {
+				return nil
+			}
+			return &ast.IndexExpression{Token: open, Left: left, Index: index}
+		}
+	}
+
+	p.nextToken()
+	slice := &ast.SliceExpression{Token: open, Left: left, Low: index}
+
+	if !p.peekTokenIS(token.RBRACKET) {
+		p.nextToken()
+		slice.High = p.parseExpression(LOWEST)
+		if slice.High == nil {
+			return nil
+		}
+	}
+
+	if !p.expectedPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return slice
+}
+
+// parseExpressionList parses a comma-separated list of expressions, starting
+// at the opening delimiter and ending at end. A trailing comma is allowed.
+// what names the list elements in error messages. It returns nil after
+// reporting an error.
+func (p *Parser) parseExpressionList(end token.TokenType, what string) []ast.Expression {
+	open := p.curToken
+	list := []ast.Expression{}
+
+	for !p.peekTokenIS(end) {
+		if p.peekTokenIS(token.COMMA) {
+			p.report(diagnostic.Errorf(diagnostic.TokenSpan(p.peekToken), codeBadList,
+				"expected an %s before ','", what))
+			return nil
+		}
+		if p.peekTokenIS(token.EOF) {
+			break
+		}
+
+		p.nextToken()
+		exp := p.parseExpression(LOWEST)
+		if exp == nil {
+			return nil
+		}
+		list = append(list, exp)
+
+		if !p.peekTokenIS(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	switch {
+	case p.peekTokenIS(end):
+		p.nextToken()
+		return list
+	case p.peekTokenIS(token.EOF):
+		d := diagnostic.Errorf(diagnostic.TokenSpan(p.peekToken), codeUnclosed,
+			"expected %s to close the %s list, found the end of input", end.Describe(), what)
+		p.report(d.WithLabel(diagnostic.TokenSpan(open), what+" list opened here"))
+	case !p.peekTokenIS(token.ILLEGAL):
+		p.report(diagnostic.Errorf(diagnostic.TokenSpan(p.peekToken), codeBadList,
+			"expected ',' or %s after %s, found %s", end.Describe(), what, p.peekToken.Describe()))
+	}
+	return nil
+}
+
 func (p *Parser) curPrecedence() int {
 	if p, ok := precedences[p.curToken.Type]; ok {
 		return p
@@ -269,7 +1098,7 @@ func (p *Parser) curPrecedence() int {
 }
 
 func (p *Parser) peekPrecedence() int {
-	if p, ok:= precedences[p.peekToken.Type]; ok {
+	if p, ok := precedences[p.peekToken.Type]; ok {
 		return p
 	}
 	return LOWEST
@@ -283,13 +1112,51 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 	p.infixParseFns[tokenType] = fn
 }
 
-// Errors returns all syntax errors collected during parsing.func (p *Parser) Errors() []string {
+// Diagnostics returns the problems found during parsing, including those
+// found by the lexer, in the order they were found.
+func (p *Parser) Diagnostics() []diagnostic.Diagnostic {
+	return p.diagnostics
+}
+
+// Errors returns all syntax errors collected during parsing. Each message
+// is prefixed with the source position it refers to.
 func (p *Parser) Errors() []string {
-	return p.errors
+	errors := []string{}
+	for _, d := range p.diagnostics {
+		errors = append(errors, d.Error())
+	}
+	return errors
+}
+
+// report records d.
+func (p *Parser) report(d diagnostic.Diagnostic) {
+	p.diagnostics = append(p.diagnostics, d)
+}
+
+// lexerDiagnostic converts a diagnostic from the lexer. Its suggested fix
+// becomes a note.
+func lexerDiagnostic(err lexer.Error) diagnostic.Diagnostic {
+	code := codeInvalidInput
+	if err.Unterminated {
+		code = codeUnterminated
+	}
+	d := diagnostic.Errorf(diagnostic.Span{Start: err.Pos, End: err.Pos}, code, "%s", err.Msg)
+	if err.Fix != "" {
+		d = d.WithNote(err.Fix)
+	}
+	return d
 }
 
 // peekError records an error when the next token does not match the expected type.
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
-}
\ No newline at end of file
+	if p.peekTokenIS(token.ILLEGAL) {
+		// the lexer has already reported why the token is illegal
+		return
+	}
+	d := diagnostic.Errorf(diagnostic.TokenSpan(p.peekToken), codeUnexpectedToken,
+		"expected %s, found %s", t.Describe(), p.peekToken.Describe())
+	if t == token.SEMICOLON {
+		d = d.WithNote("end each statement with ';'")
+	}
+	p.report(d)
+}
@@ -4,47 +4,49 @@ import (
 	"fmt"
 	"karma/ast"
 	"karma/lexer"
+	"strings"
 	"testing"
 )
 
 func TestLetStatements(t *testing.T) {
-	input := `
-		karma x = 5;
-		karma y = 10;
-		karma number = 838383;
-	`
-	l := lexer.New(input)
-	p := New(l)
-
-	program := p.ParseProgram()
-	checkParserErrors(t, p)
-	if program == nil {
-		t.Fatalf("ParseProgram() returned nil!")
-	}
-	if len(program.Statements) != 3 {
-		t.Fatalf("program.Statements does not contain 3 statements. got=%d", len(program.Statements))
-	}
-
 	tests := []struct {
+		input              string
 		expectedIdentifier string
+		expectedValue      interface{}
 	}{
-		{"x"},
-		{"y"},
-		{"number"},
+		{"karma x = 5;", "x", 5},
+		{"karma y = 10;", "y", 10},
+		{"karma number = 838383;", "number", 838383},
+		{"karma foobar = y;", "foobar", "y"},
+		{"karma total = a + b", "total", "(a + b)"},
 	}
 
-	for i, tt := range tests {
-		statement := program.Statements[i]
-		if !testLetStatement(t, statement, tt.expectedIdentifier) {
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		stmt := program.Statements[0]
+		if !testLetStatement(t, stmt, tt.expectedIdentifier) {
+			return
+		}
+
+		val := stmt.(*ast.LetStatement).Value
+		if !testLiteralExpression(t, val, tt.expectedValue) {
 			return
 		}
 	}
 }
 
-func testLetStatement(t *testing.T, s ast.Statement, name string) bool{
+func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
 	if s.TokenLiteral() != "karma" {
 		t.Errorf("s.TokenLiteral not 'let'. got=%q", s.TokenLiteral())
-		return false;
+		return false
 	}
 
 	letStmt, ok := s.(*ast.LetStatement)
@@ -66,39 +68,72 @@ func testLetStatement(t *testing.T, s ast.Statement, name string) bool{
 }
 
 func TestReturnStatement(t *testing.T) {
-	input := `
-		return 5;
-		return 10;
-		return 993322;
-	`
-	l := lexer.New(input)
-	p := New(l)
-
-	program := p.ParseProgram()
-	checkParserErrors(t, p)
-	if program == nil {
-		t.Fatalf("ParseProgram() returned nil!")
-	}
-	if len(program.Statements) != 3 {
-		t.Fatalf("program.Statements does not contain 3 statements. got=%d", len(program.Statements))
+	tests := []struct {
+		input         string
+		expectedValue interface{}
+	}{
+		{"return 5;", 5},
+		{"return 993322;", 993322},
+		{"return x;", "x"},
+		{"return a * b", "(a * b)"},
+		{"return;", nil},
+		{"return", nil},
 	}
 
-	for _, stmt := range program.Statements {
-		returnStmt, ok := stmt.(*ast.ReturnStatement)
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		returnStmt, ok := program.Statements[0].(*ast.ReturnStatement)
 		if !ok {
-			t.Errorf("stmt not *ast.returnStatement. got=%T", stmt)
-			continue
+			t.Fatalf("stmt not *ast.ReturnStatement. got=%T", program.Statements[0])
 		}
 		if returnStmt.TokenLiteral() != "return" {
 			t.Errorf("returnStmt.TokenLiteral not 'return', got %q", returnStmt.TokenLiteral())
 		}
+		if tt.expectedValue == nil {
+			if returnStmt.ReturnValue != nil {
+				t.Errorf("returnStmt.ReturnValue not nil. got=%s", returnStmt.ReturnValue)
+			}
+			continue
+		}
+		if !testLiteralExpression(t, returnStmt.ReturnValue, tt.expectedValue) {
+			return
+		}
+	}
+}
+
+func TestMissingSemicolon(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"karma x = 5 karma y = 6;", "1:13: expected ';', found the keyword 'karma'"},
+		{"return x y", "1:10: expected ';', found the name 'y'"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("input %q: expected first error %q, got=%q", tt.input, tt.expected, errors)
+		}
 	}
 }
 
-func TestIdentifierExpression (t *testing.T) {
+func TestIdentifierExpression(t *testing.T) {
 	input := "num;"
 
-	l := lexer.New(input)
+	l := lexer.New("", input)
 	p := New(l)
 
 	program := p.ParseProgram()
@@ -122,14 +157,14 @@ func TestIdentifierExpression (t *testing.T) {
 	}
 	if ident.TokenLiteral() != "num" {
 		t.Errorf("ident.TokenLiteral not %s. got=%s", "num",
-		ident.TokenLiteral())
+			ident.TokenLiteral())
 	}
 }
 
 func TestIntegerLiteralExpression(t *testing.T) {
 	input := "5;"
 
-	l := lexer.New(input)
+	l := lexer.New("", input)
 	p := New(l)
 
 	program := p.ParseProgram()
@@ -143,7 +178,7 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	if !ok {
 		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
 	}
-	
+
 	literal, ok := stmt.Expression.(*ast.IntegerLiteral)
 	if !ok {
 		t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
@@ -156,6 +191,151 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestNumberLiteralExpressions(t *testing.T) {
+	intTests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0", 0},
+		{"1_000_000", 1000000},
+		{"0x2A", 42},
+		{"0o52", 42},
+		{"0b101010", 42},
+		{"0x_ff", 255},
+	}
+
+	for _, tt := range intTests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %d. got=%d", tt.expected, literal.Value)
+		}
+	}
+
+	floatTests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{"1e-9", 1e-9},
+		{"2E10", 2e10},
+		{"6.5e+2", 650},
+		{"1_000.5", 1000.5},
+	}
+
+	for _, tt := range floatTests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestMalformedNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"0x", `1:1: hexadecimal literal "0x" has no digits`},
+		{"0b_", `1:1: binary literal "0b_" has no digits`},
+		{"0b102", `1:1: invalid digit '2' in binary literal "0b102"`},
+		{"0o78", `1:1: invalid digit '8' in octal literal "0o78"`},
+		{"0x1g", `1:1: invalid digit 'g' in hexadecimal literal "0x1g"`},
+		{"9a", `1:1: invalid digit 'a' in "9a"`},
+		{"1x1", `1:1: invalid digit 'x' in "1x1"`},
+		{"2.5e3f", `1:1: invalid digit 'f' in "2.5e3f"`},
+		{"1é", `1:1: invalid digit 'é' in "1é"`},
+		{"09a", `1:1: invalid digit 'a' in "09a"`},
+		{"1e", `1:1: exponent has no digits in "1e"`},
+		{"2.5e-", `1:1: exponent has no digits in "2.5e-"`},
+		{"017", `1:1: leading zeros are not allowed in "017"; use 0o17 for an octal number`},
+		{"00", `1:1: leading zeros are not allowed in "00"; write 0`},
+		{"08", `1:1: leading zeros are not allowed in "08"; write 8`},
+		{"0_19", `1:1: leading zeros are not allowed in "0_19"; write 19`},
+		{"1__000", `1:1: '_' must separate successive digits in "1__000"`},
+		{"100_", `1:1: '_' must separate successive digits in "100_"`},
+		{"1_e5", `1:1: '_' must separate successive digits in "1_e5"`},
+		{"9223372036854775808", `1:1: could not parse "9223372036854775808" as integer`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("input %q: expected an error, got none", tt.input)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("input %q: wrong error. expected=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestStringLiteralExpression(t *testing.T) {
+	input := `"hello world";`
+
+	l := lexer.New("", input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Value != "hello world" {
+		t.Errorf("literal.Value not %q. got=%q", "hello world", literal.Value)
+	}
+}
+
+func TestInterpolatedStringExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a ${x} b"`, `("a " + x + " b")`},
+		{`"${x}${y}"`, `(x + y)`},
+		{`"sum: ${a + b * c}!"`, `("sum: " + (a + (b * c)) + "!")`},
+		{`"outer ${"inner ${x}"}"`, `("outer " + ("inner " + x))`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if _, ok := stmt.Expression.(*ast.ConcatExpression); !ok {
+			t.Fatalf("exp not *ast.ConcatExpression. got=%T", stmt.Expression)
+		}
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
 func testIntegerLiteral(t *testing.T, il ast.Expression, value int64) bool {
 	integer, ok := il.(*ast.IntegerLiteral)
 	if !ok {
@@ -176,10 +356,56 @@ func testIntegerLiteral(t *testing.T, il ast.Expression, value int64) bool {
 	return true
 }
 
+func testIdentifier(t *testing.T, exp ast.Expression, value string) bool {
+	ident, ok := exp.(*ast.Identifier)
+	if !ok {
+		t.Errorf("exp not *ast.Identifier. got=%T", exp)
+		return false
+	}
+
+	if ident.Value != value {
+		t.Errorf("ident.Value not %s. got=%s", value, ident.Value)
+		return false
+	}
+
+	if ident.TokenLiteral() != value {
+		t.Errorf("ident.TokenLiteral not %s. got=%s", value, ident.TokenLiteral())
+		return false
+	}
+
+	return true
+}
+
+// testLiteralExpression checks exp against expected: an int is compared
+// with an integer literal and a bool with a boolean. A string is compared
+// with an identifier, or, when it starts with "(", with the String() form
+// of a compound expression.
+func testLiteralExpression(t *testing.T, exp ast.Expression, expected interface{}) bool {
+	switch v := expected.(type) {
+	case int:
+		return testIntegerLiteral(t, exp, int64(v))
+	case int64:
+		return testIntegerLiteral(t, exp, v)
+	case bool:
+		return testBooleanLiteral(t, exp, v)
+	case string:
+		if strings.HasPrefix(v, "(") {
+			if exp == nil || exp.String() != v {
+				t.Errorf("exp.String() not %q. got=%v", v, exp)
+				return false
+			}
+			return true
+		}
+		return testIdentifier(t, exp, v)
+	}
+	t.Errorf("type of exp not handled. got=%T", exp)
+	return false
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
-		input string
-		operator string
+		input        string
+		operator     string
 		integerValue int64
 	}{
 		{"!4", "!", 4},
@@ -187,7 +413,7 @@ func TestParsingPrefixExpressions(t *testing.T) {
 	}
 
 	for _, tt := range prefixTests {
-		l := lexer.New(tt.input)
+		l := lexer.New("", tt.input)
 		p := New(l)
 		program := p.ParseProgram()
 		checkParserErrors(t, p)
@@ -218,11 +444,11 @@ func TestParsingPrefixExpressions(t *testing.T) {
 
 func TestParsingInfixExpression(t *testing.T) {
 	infixTests := []struct {
-		input string
-		leftValue int64
-		operator string
+		input      string
+		leftValue  int64
+		operator   string
 		rightValue int64
-	} {
+	}{
 		{"5 + 5;", 5, "+", 5},
 		{"5 - 5;", 5, "-", 5},
 		{"5 * 5;", 5, "*", 5},
@@ -231,10 +457,17 @@ func TestParsingInfixExpression(t *testing.T) {
 		{"5 < 5;", 5, "<", 5},
 		{"5 == 5;", 5, "==", 5},
 		{"5 != 5;", 5, "!=", 5},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 >= 5;", 5, ">=", 5},
+		{"5 % 5;", 5, "%", 5},
+		{"5 && 5;", 5, "&&", 5},
+		{"5 || 5;", 5, "||", 5},
+		{"5 .. 5;", 5, "..", 5},
+		{"5 |> 5;", 5, "|>", 5},
 	}
 
 	for _, tt := range infixTests {
-		l := lexer.New(tt.input)
+		l := lexer.New("", tt.input)
 		p := New(l)
 		program := p.ParseProgram()
 		checkParserErrors(t, p)
@@ -269,7 +502,7 @@ func TestParsingInfixExpression(t *testing.T) {
 
 func TestOperatorPrecedenceParsing(t *testing.T) {
 	tests := []struct {
-		input string
+		input    string
 		expected string
 	}{
 		{
@@ -324,10 +557,114 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"3 + 4 * 5 == 3 * 1 + 4 * 5",
 			"((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))",
 		},
+		{
+			"x1 + y2 * z3",
+			"(x1 + (y2 * z3))",
+		},
+		{
+			"true",
+			"true",
+		},
+		{
+			"3 > 5 == false",
+			"((3 > 5) == false)",
+		},
+		{
+			"!true != false",
+			"((!true) != false)",
+		},
+		{
+			"1 + (2 + 3) + 4",
+			"((1 + (2 + 3)) + 4)",
+		},
+		{
+			"(5 + 5) * 2",
+			"((5 + 5) * 2)",
+		},
+		{
+			"-(5 + 5)",
+			"(-(5 + 5))",
+		},
+		{
+			"!(true == true)",
+			"(!(true == true))",
+		},
+		{
+			"a + add(b * c) + d",
+			"((a + add((b * c))) + d)",
+		},
+		{
+			"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))",
+			"add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))",
+		},
+		{
+			"add(a + b + c * d / f + g)",
+			"add((((a + b) + ((c * d) / f)) + g))",
+		},
+		{
+			"-f(x) * g()(y)",
+			"((-f(x)) * g()(y))",
+		},
+		{
+			"xs |> map(double)",
+			"(xs |> map(double))",
+		},
+		{
+			"a % b * c",
+			"((a % b) * c)",
+		},
+		{
+			"a <= b == c >= d",
+			"((a <= b) == (c >= d))",
+		},
+		{
+			"a || b && c == d",
+			"(a || (b && (c == d)))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"1 + 2 .. n * 2 < m",
+			"(((1 + 2) .. (n * 2)) < m)",
+		},
+		{
+			"a + 1 |> f |> g",
+			"(((a + 1) |> f) |> g)",
+		},
+		{
+			"x = y += a || b",
+			"(x = (y += (a || b)))",
+		},
+		{
+			"x -= 1 + 2 * 3",
+			"(x -= (1 + (2 * 3)))",
+		},
+		{
+			"a + /* two /* nested */ */ b // trailing",
+			"(a + b)",
+		},
+		{
+			"a * [1, 2, 3, 4][b * c] * d",
+			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
+		},
+		{
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
+		},
+		{
+			"-xs[-1]",
+			"(-(xs[(-1)]))",
+		},
+		{
+			"f(x)[0][1:]",
+			"((f(x)[0])[1:])",
+		},
 	}
 
 	for _, tt := range tests {
-		l := lexer.New(tt.input)
+		l := lexer.New("", tt.input)
 		p := New(l)
 		program := p.ParseProgram()
 		checkParserErrors(t, p)
@@ -339,16 +676,880 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 	}
 }
 
-func checkParserErrors(t *testing.T, p *Parser) {
+func TestAssignExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		name     string
+		operator string
+		value    int64
+	}{
+		{"x = 5;", "x", "=", 5},
+		{"total += 10;", "total", "+=", 10},
+		{"n -= 1;", "n", "-=", 1},
+		{"n *= 2;", "n", "*=", 2},
+		{"n /= 3;", "n", "/=", 3},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.AssignExpression. got=%T", stmt.Expression)
+		}
+		if exp.Name.Value != tt.name {
+			t.Errorf("exp.Name.Value not %q. got=%q", tt.name, exp.Name.Value)
+		}
+		if exp.Operator != tt.operator {
+			t.Errorf("exp.Operator not %q. got=%q", tt.operator, exp.Operator)
+		}
+		testIntegerLiteral(t, exp.Value, tt.value)
+	}
+}
+
+func TestInvalidAssignmentTarget(t *testing.T) {
+	l := lexer.New("", "1 + 2 = 3")
+	p := New(l)
+	p.ParseProgram()
+
 	errors := p.Errors()
+	expected := "1:7: cannot assign to (1 + 2)"
+	if len(errors) == 0 || errors[0] != expected {
+		t.Fatalf("wrong errors. expected first=%q, got=%q", expected, errors)
+	}
+}
 
-	if len(errors) == 0 {
-		return
+func TestLexerErrorsAreReported(t *testing.T) {
+	input := `x @ 5;
+y = "open`
+
+	l := lexer.New("main.ka", input)
+	p := New(l)
+	p.ParseProgram()
+
+	expected := []string{
+		"main.ka:1:3: unexpected character '@'",
+		`main.ka:2:5: unterminated string literal`,
 	}
 
-	t.Errorf("parser has %d errors", len(errors))
-	for _, msg := range errors {
-		t.Errorf("parser error: %q", msg)
+	errors := p.Errors()
+	if len(errors) != len(expected) {
+		t.Fatalf("wrong number of errors. expected=%q, got=%q", expected, errors)
 	}
-	t.FailNow()
-}
\ No newline at end of file
+	for i, msg := range expected {
+		if errors[i] != msg {
+			t.Errorf("errors[%d] wrong. expected=%q, got=%q", i, msg, errors[i])
+		}
+	}
+}
+
+func testBooleanLiteral(t *testing.T, exp ast.Expression, value bool) bool {
+	bo, ok := exp.(*ast.Boolean)
+	if !ok {
+		t.Errorf("exp not *ast.Boolean. got=%T", exp)
+		return false
+	}
+
+	if bo.Value != value {
+		t.Errorf("bo.Value not %t. got=%t", value, bo.Value)
+		return false
+	}
+
+	if bo.TokenLiteral() != fmt.Sprintf("%t", value) {
+		t.Errorf("bo.TokenLiteral not %t. got=%s", value, bo.TokenLiteral())
+		return false
+	}
+
+	return true
+}
+
+func TestBooleanExpression(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedBoolean bool
+	}{
+		{"true;", true},
+		{"false;", false},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program has not enough statements. got=%d", len(program.Statements))
+		}
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		testBooleanLiteral(t, stmt.Expression, tt.expectedBoolean)
+	}
+}
+
+func TestIfExpression(t *testing.T) {
+	input := `if (x < y) { x }`
+
+	l := lexer.New("", input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d", 1, len(program.Statements))
+	}
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.IfExpression. got=%T", stmt.Expression)
+	}
+
+	if !testLiteralExpression(t, exp.Condition, "(x < y)") {
+		return
+	}
+
+	if len(exp.Consequence.Statements) != 1 {
+		t.Errorf("consequence is not 1 statements. got=%d", len(exp.Consequence.Statements))
+	}
+
+	consequence, ok := exp.Consequence.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Statements[0] is not ast.ExpressionStatement. got=%T", exp.Consequence.Statements[0])
+	}
+
+	if !testIdentifier(t, consequence.Expression, "x") {
+		return
+	}
+
+	if exp.Alternative != nil {
+		t.Errorf("exp.Alternative was not nil. got=%+v", exp.Alternative)
+	}
+}
+
+func TestIfElseExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"if (x < y) { x } else { y }", "if (x < y) { x } else { y }"},
+		{"if (x) { return 1; } else if (y) { 2 } else { 3 }", "if x { return 1; } else { if y { 2 } else { 3 } }"},
+		{"if (x) { karma a = 1; a } else {}", "if x { karma a = 1; a } else { }"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.IfExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.IfExpression. got=%T", stmt.Expression)
+		}
+		if exp.Alternative == nil {
+			t.Fatalf("exp.Alternative is nil")
+		}
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestFunctionLiteralParsing(t *testing.T) {
+	input := `fun(x, y) { x + y; }`
+
+	l := lexer.New("", input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d", 1, len(program.Statements))
+	}
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	function, ok := stmt.Expression.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.FunctionLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(function.Parameters) != 2 {
+		t.Fatalf("function literal parameters wrong. want 2, got=%d", len(function.Parameters))
+	}
+
+	testIdentifier(t, function.Parameters[0], "x")
+	testIdentifier(t, function.Parameters[1], "y")
+
+	if len(function.Body.Statements) != 1 {
+		t.Fatalf("function.Body.Statements has not 1 statements. got=%d", len(function.Body.Statements))
+	}
+
+	bodyStmt, ok := function.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("function body stmt is not ast.ExpressionStatement. got=%T", function.Body.Statements[0])
+	}
+
+	testLiteralExpression(t, bodyStmt.Expression, "(x + y)")
+}
+
+func TestFunctionParameterParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+	}{
+		{input: "fun() {};", expectedParams: []string{}},
+		{input: "fun(x) {};", expectedParams: []string{"x"}},
+		{input: "fun(x, y, z) {};", expectedParams: []string{"x", "y", "z"}},
+		{input: "fun(x, y,) {};", expectedParams: []string{"x", "y"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Errorf("length parameters wrong. want %d, got=%d", len(tt.expectedParams), len(function.Parameters))
+		}
+
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+		}
+	}
+}
+
+func TestBlockAndFunctionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fun(x, x) { x }", "1:8: duplicate parameter x"},
+		{"fun(x y) { x }", "1:7: expected ')', found the name 'y'"},
+		{"fun(1) { }", "1:5: expected a name, found the number 1"},
+		{"if (x) { karma y = 1;", "1:22: expected '}' to close the block, found the end of input"},
+		{"if x { y }", "1:4: expected '(', found the name 'x'"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("input %q: expected first error %q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestCallExpressionParsing(t *testing.T) {
+	input := "add(1, 2 * 3, 4 + 5);"
+
+	l := lexer.New("", input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d", 1, len(program.Statements))
+	}
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, exp.Function, "add") {
+		return
+	}
+
+	if len(exp.Arguments) != 3 {
+		t.Fatalf("wrong length of arguments. got=%d", len(exp.Arguments))
+	}
+
+	testLiteralExpression(t, exp.Arguments[0], 1)
+	testLiteralExpression(t, exp.Arguments[1], "(2 * 3)")
+	testLiteralExpression(t, exp.Arguments[2], "(4 + 5)")
+}
+
+func TestCallExpressionArgumentParsing(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedIdent string
+		expectedArgs  []string
+	}{
+		{"add();", "add", []string{}},
+		{"add(1);", "add", []string{"1"}},
+		{"add(1, 2 * 3, 4 + 5);", "add", []string{"1", "(2 * 3)", "(4 + 5)"}},
+		{"add(a, g(c),);", "add", []string{"a", "g(c)"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.CallExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+		}
+
+		if !testIdentifier(t, exp.Function, tt.expectedIdent) {
+			return
+		}
+
+		if len(exp.Arguments) != len(tt.expectedArgs) {
+			t.Fatalf("wrong number of arguments. want=%d, got=%d", len(tt.expectedArgs), len(exp.Arguments))
+		}
+
+		for i, arg := range tt.expectedArgs {
+			if exp.Arguments[i].String() != arg {
+				t.Errorf("argument %d wrong. want=%q, got=%q", i, arg, exp.Arguments[i].String())
+			}
+		}
+	}
+}
+
+func TestMalformedArgumentLists(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"add(,)", "1:5: expected an argument before ','"},
+		{"add(1,,2)", "1:7: expected an argument before ','"},
+		{"add(1 2)", "1:7: expected ',' or ')' after argument, found the number 2"},
+		{"add(1, 2", "1:9: expected ')' to close the argument list, found the end of input"},
+		{"add(", "1:5: expected ')' to close the argument list, found the end of input"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("input %q: expected first error %q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestArrayLiteralParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"[]", []string{}},
+		{"[1, 2 * 2, 3 + 3]", []string{"1", "(2 * 2)", "(3 + 3)"}},
+		{"[\"a\", [b],]", []string{`"a"`, "[b]"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		array, ok := stmt.Expression.(*ast.ArrayLiteral)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.ArrayLiteral. got=%T", stmt.Expression)
+		}
+
+		if len(array.Elements) != len(tt.expected) {
+			t.Fatalf("wrong number of elements. want=%d, got=%d", len(tt.expected), len(array.Elements))
+		}
+
+		for i, el := range tt.expected {
+			if array.Elements[i].String() != el {
+				t.Errorf("element %d wrong. want=%q, got=%q", i, el, array.Elements[i].String())
+			}
+		}
+	}
+}
+
+func TestIndexExpressionParsing(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	l := lexer.New("", input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.IndexExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, exp.Left, "myArray") {
+		return
+	}
+	testLiteralExpression(t, exp.Index, "(1 + 1)")
+}
+
+func TestSliceExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedLow  string
+		expectedHigh string
+	}{
+		{"xs[1:3]", "1", "3"},
+		{"xs[:n - 1]", "", "(n - 1)"},
+		{"xs[-2:]", "(-2)", ""},
+		{"xs[:]", "", ""},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.SliceExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.SliceExpression. got=%T", stmt.Expression)
+		}
+
+		if !testIdentifier(t, exp.Left, "xs") {
+			return
+		}
+		if got := boundString(exp.Low); got != tt.expectedLow {
+			t.Errorf("%s: wrong low bound. want=%q, got=%q", tt.input, tt.expectedLow, got)
+		}
+		if got := boundString(exp.High); got != tt.expectedHigh {
+			t.Errorf("%s: wrong high bound. want=%q, got=%q", tt.input, tt.expectedHigh, got)
+		}
+	}
+}
+
+func boundString(exp ast.Expression) string {
+	if exp == nil {
+		return ""
+	}
+	return exp.String()
+}
+
+func TestMalformedArrays(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[,]", "1:2: expected an element before ','"},
+		{"[1 2]", "1:4: expected ',' or ']' after element, found the number 2"},
+		{"[1, 2", "1:6: expected ']' to close the element list, found the end of input"},
+		{"xs[1", "1:5: expected ']', found the end of input"},
+		{"xs[1:2:3]", "1:7: expected ']', found ':'"},
+		{"xs[]", "1:4: expected an expression, found ']'"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("input %q: expected first error %q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestHashLiteralParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"{}", "{}"},
+		{`{"name": "amer", 1: true}`, `{"name": "amer", 1: true}`},
+		{`{"one": 0 + 1, two: 10 - 8,}`, `{"one": (0 + 1), two: (10 - 8)}`},
+		{`{"xs": [1, 2], "h": {}}["xs"][0]`, `(({"xs": [1, 2], "h": {}}["xs"])[0])`},
+		{`karma h = {1: 2};`, `karma h = {1: 2};`},
+		{`if (x) { {"a": 1} }`, `if x { {"a": 1} }`},
+		{`fun() { {} }`, `fun() { {} }`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if got := program.String(); got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestHashLiteralPairs(t *testing.T) {
+	l := lexer.New("", `{"one": 1, "two": 2, "three": 3}`)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	expected := []struct {
+		key   string
+		value int64
+	}{{"one", 1}, {"two", 2}, {"three", 3}}
+
+	if len(hash.Pairs) != len(expected) {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	for i, pair := range hash.Pairs {
+		key, ok := pair.Key.(*ast.StringLiteral)
+		if !ok {
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
+			continue
+		}
+		if key.Value != expected[i].key {
+			t.Errorf("pair %d has wrong key. want=%q, got=%q", i, expected[i].key, key.Value)
+		}
+		testIntegerLiteral(t, pair.Value, expected[i].value)
+	}
+}
+
+func TestMalformedHashes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"a" 1}`, "1:6: expected ':', found the number 1"},
+		{`{"a": 1 "b": 2}`, "1:9: expected ',' or '}' after hash entry, found a string"},
+		{`{"a": }`, "1:7: expected an expression, found '}'"},
+		{`{,}`, "1:2: expected an expression, found ','"},
+		{`{"a": 1,`, "1:9: expected '}' to close the hash, found the end of input"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("input %q: expected first error %q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	l := lexer.New("", "while (x < 10) { x += 1; }")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement. got=%T", program.Statements[0])
+	}
+
+	if got := stmt.Condition.String(); got != "(x < 10)" {
+		t.Errorf("wrong condition. got=%q", got)
+	}
+	if len(stmt.Body.Statements) != 1 {
+		t.Fatalf("body does not contain 1 statement. got=%d", len(stmt.Body.Statements))
+	}
+	if got := stmt.Body.String(); got != "{ (x += 1) }" {
+		t.Errorf("wrong body. got=%q", got)
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	l := lexer.New("", "for (item in 0..len(xs)) { print(item) };")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ForStatement. got=%T", program.Statements[0])
+	}
+
+	if !testIdentifier(t, stmt.Variable, "item") {
+		return
+	}
+	if got := stmt.Iterable.String(); got != "(0 .. len(xs))" {
+		t.Errorf("wrong iterable. got=%q", got)
+	}
+	if got := stmt.String(); got != "for item in (0 .. len(xs)) { print(item) }" {
+		t.Errorf("wrong String(). got=%q", got)
+	}
+}
+
+func TestLoopControl(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"while (true) { break; }", "while true { break; }"},
+		{"while (true) { continue }", "while true { continue; }"},
+		{"for (x in xs) { if (x) { break } }", "for x in xs { if x { break; } }"},
+		{"while (a) { while (b) { break; }; continue; }", "while a { while b { break; } continue; }"},
+		{"while (a) { karma f = fun() { while (b) { break } }; }", "while a { karma f = fun() { while b { break; } }; }"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if got := program.String(); got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestMalformedLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"break;", []string{"1:1: break outside a loop"}},
+		{"continue", []string{"1:1: continue outside a loop"}},
+		{"if (x) { break; }", []string{"1:10: break outside a loop"}},
+		{"while (true) { karma f = fun() { break; }; }", []string{"1:34: break outside a loop"}},
+		{"while (true) { } break; karma x = 1;", []string{"1:18: break outside a loop"}},
+		{"while (true) { break 1; }", []string{"1:22: expected ';', found the number 1"}},
+		{"while true { }", []string{"1:7: expected '(', found the keyword 'true'"}},
+		{"for (x of xs) { }", []string{"1:8: expected the keyword 'in', found the name 'of'"}},
+		{"for (1 in xs) { }", []string{"1:6: expected a name, found the number 1"}},
+		{"while (x) x += 1;", []string{"1:11: expected '{', found the name 'x'"}},
+		{"karma a = 1 while (a) { }", []string{"1:13: expected ';', found the keyword 'while'"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if got := p.Errors(); strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("input %q: wrong errors.\nwant=%q\n got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func checkParserErrors(t *testing.T, p *Parser) {
+	errors := p.Errors()
+
+	if len(errors) == 0 {
+		return
+	}
+
+	t.Errorf("parser has %d errors", len(errors))
+	for _, msg := range errors {
+		t.Errorf("parser error: %q", msg)
+	}
+	t.FailNow()
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input      string
+		errors     []string
+		statements []string
+	}{
+		{
+			"karma x = 1 +; karma y = 2;",
+			[]string{"1:14: expected an expression, found ';'"},
+			[]string{"karma x = <bad expression>;", "karma y = 2;"},
+		},
+		{
+			"karma 5 = 1 2 3; x",
+			[]string{"1:7: expected a name, found the number 5"},
+			[]string{"<bad statement>", "x"},
+		},
+		{
+			"karma x = 1 2 3; x",
+			[]string{"1:13: expected ';', found the number 2"},
+			[]string{"karma x = 1;", "x"},
+		},
+		{
+			"karma x = karma y = 2; y",
+			[]string{"1:11: expected an expression, found the keyword 'karma'"},
+			[]string{"karma x = <bad expression>;", "karma y = 2;", "y"},
+		},
+		{
+			"return ) ) return 1",
+			[]string{"1:8: expected an expression, found ')'"},
+			[]string{"return <bad expression>;", "return 1;"},
+		},
+		{
+			"karma f = fun() { 1 + }; f()",
+			[]string{"1:23: expected an expression, found '}'"},
+			[]string{"karma f = fun() { <bad statement> };", "f()"},
+		},
+		{
+			"if (x) { karma = 1; y } karma z = 2;",
+			[]string{"1:16: expected a name, found '='"},
+			[]string{"if x { <bad statement> y }", "karma z = 2;"},
+		},
+		{
+			"(1 + { 2 ; 3 }) karma z = 2;",
+			[]string{"1:10: expected ':', found ';'"},
+			[]string{"<bad statement>", "karma z = 2;"},
+		},
+		{
+			"1; } karma z = 2;",
+			[]string{"1:4: expected an expression, found '}'"},
+			[]string{"1", "<bad statement>", "karma z = 2;"},
+		},
+		{
+			"a || ) - 1 += 2",
+			[]string{"1:6: expected an expression, found ')'"},
+			[]string{"<bad statement>"},
+		},
+		{
+			"y || } -1; karma z = 2;",
+			[]string{"1:6: expected an expression, found '}'"},
+			[]string{"<bad statement>", "karma z = 2;"},
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if strings.Join(p.Errors(), "\n") != strings.Join(tt.errors, "\n") {
+			t.Errorf("input %q: wrong errors.\nwant=%q\n got=%q", tt.input, tt.errors, p.Errors())
+		}
+
+		var statements []string
+		for _, stmt := range program.Statements {
+			statements = append(statements, stmt.String())
+		}
+		if strings.Join(statements, "\n") != strings.Join(tt.statements, "\n") {
+			t.Errorf("input %q: wrong statements.\nwant=%q\n got=%q", tt.input, tt.statements, statements)
+		}
+	}
+}
+
+func TestBadNodeSpans(t *testing.T) {
+	l := lexer.New("", "karma x = 1 +;\nkarma 5 = 2; karma y = 3;")
+	p := New(l)
+	program := p.ParseProgram()
+
+	let, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.LetStatement. got=%T", program.Statements[0])
+	}
+	bad, ok := let.Value.(*ast.BadExpression)
+	if !ok {
+		t.Fatalf("let.Value is not *ast.BadExpression. got=%T", let.Value)
+	}
+	if bad.Token.Start.String() != "1:11" || bad.End.String() != "1:15" {
+		t.Errorf("wrong BadExpression span. got=%s-%s", bad.Token.Start, bad.End)
+	}
+
+	stmt, ok := program.Statements[1].(*ast.BadStatement)
+	if !ok {
+		t.Fatalf("program.Statements[1] is not *ast.BadStatement. got=%T", program.Statements[1])
+	}
+	if stmt.Token.Start.String() != "2:1" || stmt.End.String() != "2:13" {
+		t.Errorf("wrong BadStatement span. got=%s-%s", stmt.Token.Start, stmt.End)
+	}
+}
+
+func TestErrorLimit(t *testing.T) {
+	input := strings.Repeat("karma = 1;\n", 20)
+
+	l := lexer.New("", input)
+	p := New(l)
+	program := p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != maxErrors+1 {
+		t.Fatalf("wrong number of errors. want=%d, got=%d: %q", maxErrors+1, len(errors), errors)
+	}
+	if last := errors[maxErrors]; last != "11:1: too many errors" {
+		t.Errorf("wrong last error. got=%q", last)
+	}
+	if len(program.Statements) != maxErrors {
+		t.Errorf("wrong number of statements. want=%d, got=%d", maxErrors, len(program.Statements))
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input  string
+		code   string
+		span   string
+		labels []string
+		notes  []string
+	}{
+		{"karma x = 1 2", codeUnexpectedToken, "1:13-1:14", nil, []string{"end each statement with ';'"}},
+		{"1 + ;", codeMissingOperand, "1:5-1:6", nil, nil},
+		{"fun(a, b, a) { }", codeDuplicateParam, "1:11-1:12", []string{"1:5: first declared here"}, nil},
+		{"if (x) {\n  y", codeUnclosed, "2:4-2:4", []string{"1:8: block opened here"}, nil},
+		{"f(1,", codeUnclosed, "1:5-1:5", []string{"1:2: argument list opened here"}, nil},
+		{"1 = 2", codeBadAssignment, "1:3-1:4", nil, []string{"only a name can be assigned to, as in x = 1"}},
+		{"0x", codeBadNumber, "1:1-1:3", nil, nil},
+		{"\"open", codeUnterminated, "1:1-1:1", nil, []string{`add a closing '"'`}},
+		{"x @", codeInvalidInput, "1:3-1:3", nil, []string{"remove this character"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) == 0 {
+			t.Errorf("input %q: no diagnostics", tt.input)
+			continue
+		}
+		d := diagnostics[0]
+
+		if d.Code != tt.code {
+			t.Errorf("input %q: wrong code. want=%s, got=%s (%s)", tt.input, tt.code, d.Code, d.Message)
+		}
+		if span := fmt.Sprintf("%s-%s", d.Span.Start, d.Span.End); span != tt.span {
+			t.Errorf("input %q: wrong span. want=%s, got=%s", tt.input, tt.span, span)
+		}
+
+		var labels []string
+		for _, label := range d.Labels {
+			labels = append(labels, fmt.Sprintf("%s: %s", label.Span.Start, label.Message))
+		}
+		if fmt.Sprint(labels) != fmt.Sprint(tt.labels) {
+			t.Errorf("input %q: wrong labels. want=%q, got=%q", tt.input, tt.labels, labels)
+		}
+		if fmt.Sprint(d.Notes) != fmt.Sprint(tt.notes) {
+			t.Errorf("input %q: wrong notes. want=%q, got=%q", tt.input, tt.notes, d.Notes)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package repl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"karma/lexer"
+	"karma/object"
+	"karma/token"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// command is a REPL command such as :load.
+type command struct {
+	name string
+	args string // argument synopsis, for :help
+	help string
+	run  func(s *session, arg string)
+}
+
+// commands lists the REPL commands in the order :help shows them. It is
+// filled in by init because :help itself refers to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{":help", "", "list the commands", (*session).help},
+		{":load", "<file>", "run a file in the current session", (*session).load},
+		{":save", "<file>", "save the inputs of this session as a script", (*session).save},
+		{":reset", "", "forget all bindings and history", (*session).reset},
+		{":env", "", "list the bindings and their values", (*session).listEnv},
+		{":type", "<expr>", "show the runtime type of an expression", (*session).showType},
+		{":time", "<expr>", "evaluate an expression and show how long it took", (*session).time},
+		{":tokens", "[input]", "print the tokens of input, or switch to token mode", (*session).tokens},
+		{":ast", "[input]", "print the syntax tree of input, or switch to AST mode", (*session).syntaxTree},
+		{":eval", "", "switch back to evaluating inputs", (*session).evalMode},
+	}
+}
+
+// command runs a colon command.
+func (s *session) command(line string) {
+	name, arg := line, ""
+	if i := strings.IndexAny(line, " \t\n"); i >= 0 {
+		name, arg = line[:i], strings.TrimSpace(line[i:])
+	}
+
+	for _, c := range commands {
+		if c.name == name {
+			c.run(s, arg)
+			return
+		}
+	}
+	fmt.Fprintf(s.out, "unknown command %s; type :help for a list\n", name)
+}
+
+func (s *session) help(string) {
+	for _, c := range commands {
+		fmt.Fprintf(s.out, "%-8s %-8s %s\n", c.name, c.args, c.help)
+	}
+}
+
+// load runs the file at path in the session environment. Its source is
+// added to the history, so a saved session does not depend on the file;
+// a #! line at its start is left out, since it could not come first in
+// the saved script.
+func (s *session) load(path string) {
+	if path == "" {
+		fmt.Fprintln(s.out, "usage: :load <file>")
+		return
+	}
+
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(s.out, "cannot load: %s\n", err)
+		return
+	}
+
+	evaluated, ok := s.evalInput(path, stripShebang(string(src)))
+	if ok && evaluated != nil {
+		fmt.Fprintln(s.out, evaluated.Inspect())
+	}
+}
+
+// save writes the history to path as a script that recreates the session.
+// A path without an extension gets ".karma".
+func (s *session) save(path string) {
+	if path == "" {
+		fmt.Fprintln(s.out, "usage: :save <file>")
+		return
+	}
+	if filepath.Ext(path) == "" {
+		path += ".karma"
+	}
+
+	// An input may end in a // comment, so a missing semicolon goes on a
+	// line of its own.
+	var script strings.Builder
+	for _, input := range s.history {
+		script.WriteString(strings.TrimSpace(input))
+		script.WriteString("\n")
+		if needsSemicolon(input) {
+			script.WriteString(";\n")
+		}
+	}
+
+	if err := ioutil.WriteFile(path, []byte(script.String()), 0644); err != nil {
+		fmt.Fprintf(s.out, "cannot save: %s\n", err)
+		return
+	}
+	fmt.Fprintf(s.out, "saved %d inputs to %s\n", len(s.history), path)
+}
+
+// stripShebang blanks out a #! line at the start of src. The line break
+// is kept, so positions still match the file.
+func stripShebang(src string) string {
+	if !strings.HasPrefix(src, "#!") {
+		return src
+	}
+	if i := strings.IndexByte(src, '\n'); i >= 0 {
+		return src[i:]
+	}
+	return ""
+}
+
+// needsSemicolon reports whether input must be followed by a semicolon to
+// be joined with the next input: it has tokens, and the last is not one.
+func needsSemicolon(input string) bool {
+	l := lexer.New("", input)
+	var last token.TokenType
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		last = tok.Type
+	}
+	return last != "" && last != token.SEMICOLON
+}
+
+func (s *session) reset(string) {
+	s.env = object.NewConfiguredEnvironment(s.env.Config())
+	s.history = nil
+	fmt.Fprintln(s.out, "session reset")
+}
+
+func (s *session) listEnv(string) {
+	names := s.env.Names()
+	if len(names) == 0 {
+		fmt.Fprintln(s.out, "no bindings")
+		return
+	}
+	for _, name := range names {
+		val, _ := s.env.Get(name)
+		fmt.Fprintf(s.out, "%s = %s\n", name, val.Inspect())
+	}
+}
+
+// showType evaluates expr and prints the type of its value. Like :time,
+// it keeps expr out of the history unless it changed a binding, which
+// :save must then reproduce.
+func (s *session) showType(expr string) {
+	if expr == "" {
+		fmt.Fprintln(s.out, "usage: :type <expr>")
+		return
+	}
+
+	before := s.bindings()
+	evaluated, ok := s.evalSource("", expr)
+	if s.rebound(before) {
+		s.record(expr, evaluated, ok)
+	}
+	switch {
+	case !ok:
+	case evaluated == nil:
+		fmt.Fprintln(s.out, "no value")
+	case evaluated.Type() == object.ERROR_OBJ:
+		fmt.Fprintln(s.out, evaluated.Inspect())
+	default:
+		fmt.Fprintln(s.out, evaluated.Type())
+	}
+}
+
+// time evaluates expr, prints its value and how long parsing and
+// evaluation took.
+func (s *session) time(expr string) {
+	if expr == "" {
+		fmt.Fprintln(s.out, "usage: :time <expr>")
+		return
+	}
+
+	before := s.bindings()
+	start := time.Now()
+	evaluated, ok := s.evalSource("", expr)
+	elapsed := time.Since(start)
+	if s.rebound(before) {
+		s.record(expr, evaluated, ok)
+	}
+	if !ok {
+		return
+	}
+
+	if evaluated != nil {
+		fmt.Fprintln(s.out, evaluated.Inspect())
+	}
+	fmt.Fprintf(s.out, "took %s\n", elapsed)
+}
+
+func (s *session) tokens(input string) {
+	if input == "" {
+		s.mode = modeTokens
+		return
+	}
+	s.printTokens(input)
+}
+
+func (s *session) syntaxTree(input string) {
+	if input == "" {
+		s.mode = modeAST
+		return
+	}
+	s.printAST(input)
+}
+
+func (s *session) evalMode(string) {
+	s.mode = modeEval
+}
@@ -0,0 +1,442 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// errInterrupted is returned by a lineReader when the user abandons the
+// line being entered.
+var errInterrupted = errors.New("interrupted")
+
+// A lineReader reads the REPL's input one line at a time.
+type lineReader interface {
+	// ReadLine shows prompt and returns the next line of input, without
+	// its line ending. It returns io.EOF once the input is exhausted and
+	// errInterrupted when the line is abandoned.
+	ReadLine(prompt string) (string, error)
+}
+
+// scanReader reads plain lines, for input that is not a terminal.
+type scanReader struct {
+	out   io.Writer
+	lines <-chan string
+
+	// listen starts delivering interrupts on the channel it returns, until
+	// stop is called. ReadLine listens only while it waits for a line.
+	listen func() (interrupts <-chan os.Signal, stop func())
+}
+
+func (r *scanReader) ReadLine(prompt string) (string, error) {
+	interrupts, stop := r.listen()
+	defer stop()
+
+	fmt.Fprint(r.out, prompt)
+
+	select {
+	case line, ok := <-r.lines:
+		if !ok {
+			return "", io.EOF
+		}
+		return line, nil
+	case <-interrupts:
+		return "", errInterrupted
+	}
+}
+
+// historyFile is the name of the file, in the user's home directory, that
+// keeps the lines entered in the REPL across sessions.
+const historyFile = ".karma_history"
+
+// maxHistory is the number of lines of history kept.
+const maxHistory = 1000
+
+// history is the list of lines entered so far, oldest first.
+type history struct {
+	entries []string
+
+	// path is the file new entries are appended to, or "" to keep them
+	// in memory only.
+	path string
+}
+
+// loadHistory reads the history kept in the file at path. A missing or
+// unreadable file yields an empty history.
+func loadHistory(path string) *history {
+	h := &history{path: path}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return h
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		h.entries = append(h.entries, scanner.Text())
+	}
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+	}
+	return h
+}
+
+// defaultHistoryPath returns the path of the history file in the user's
+// home directory, or "" when there is no home directory.
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, historyFile)
+}
+
+// add records line, unless it is blank or repeats the previous entry, and
+// appends it to the history file. History is a convenience, so once the
+// file cannot be written it is only kept in memory.
+func (h *history) add(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if n := len(h.entries); n > 0 && h.entries[n-1] == line {
+		return
+	}
+
+	h.entries = append(h.entries, line)
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[1:]
+	}
+
+	if h.path == "" {
+		return
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		h.path = ""
+		return
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		h.path = ""
+	}
+}
+
+// editor reads lines from a terminal, with line editing, history and tab
+// completion. It understands the usual keys:
+//
+//	Left, Right, Ctrl-B, Ctrl-F     move the cursor
+//	Home, End, Ctrl-A, Ctrl-E       move to the start or end of the line
+//	Up, Down, Ctrl-P, Ctrl-N        recall earlier lines
+//	Backspace, Delete, Ctrl-D       delete a character
+//	Ctrl-U, Ctrl-K, Ctrl-W          delete to the start or end of the line, or a word
+//	Ctrl-L                          clear the screen
+//	Tab                             complete the word before the cursor
+//	Ctrl-C                          abandon the line
+//	Ctrl-D on an empty line         end the input
+type editor struct {
+	in      *bufio.Reader
+	out     io.Writer
+	history *history
+
+	// complete returns the words that start with the given prefix.
+	complete func(prefix string) []string
+
+	// raw switches the terminal to raw mode for the duration of a
+	// ReadLine; it is nil when in is not a terminal, as in tests.
+	raw func() (restore func(), err error)
+}
+
+// line is the state of the line being edited.
+type line struct {
+	prompt string
+	buf    []rune
+	pos    int // cursor position in buf
+}
+
+// ctrl returns the character sent by the terminal for Ctrl and key.
+func ctrl(key rune) rune {
+	return key & 0x1f
+}
+
+func (e *editor) ReadLine(prompt string) (string, error) {
+	if e.raw != nil {
+		restore, err := e.raw()
+		if err != nil {
+			return "", err
+		}
+		defer restore()
+	}
+
+	l := &line{prompt: prompt}
+	fmt.Fprint(e.out, prompt)
+
+	// recalled is the index of the history entry shown, and draft the
+	// line as it was before the history was browsed.
+	recalled := len(e.history.entries)
+	var draft []rune
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\n")
+			text := string(l.buf)
+			e.history.add(text)
+			return text, nil
+		case ctrl('C'):
+			fmt.Fprint(e.out, "^C")
+			return "", errInterrupted
+		case ctrl('D'):
+			if len(l.buf) == 0 {
+				return "", io.EOF
+			}
+			l.delete(l.pos, l.pos+1)
+		case ctrl('A'):
+			l.pos = 0
+		case ctrl('E'):
+			l.pos = len(l.buf)
+		case ctrl('B'):
+			l.move(-1)
+		case ctrl('F'):
+			l.move(1)
+		case ctrl('P'), ctrl('N'):
+			step := -1
+			if r == ctrl('N') {
+				step = 1
+			}
+			recalled, draft = e.recall(l, recalled, step, draft)
+		case ctrl('H'), 127:
+			if l.pos > 0 {
+				l.delete(l.pos-1, l.pos)
+			}
+		case ctrl('U'):
+			l.delete(0, l.pos)
+		case ctrl('K'):
+			l.delete(l.pos, len(l.buf))
+		case ctrl('W'):
+			end := l.pos
+			for l.pos > 0 && l.buf[l.pos-1] == ' ' {
+				l.pos--
+			}
+			l.delete(l.wordStart(), end)
+		case ctrl('L'):
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case '\t':
+			e.completeWord(l)
+		case 27:
+			key, err := e.readEscape()
+			if err != nil {
+				return "", err
+			}
+			switch key {
+			case keyLeft:
+				l.move(-1)
+			case keyRight:
+				l.move(1)
+			case keyHome:
+				l.pos = 0
+			case keyEnd:
+				l.pos = len(l.buf)
+			case keyDelete:
+				l.delete(l.pos, l.pos+1)
+			case keyUp:
+				recalled, draft = e.recall(l, recalled, -1, draft)
+			case keyDown:
+				recalled, draft = e.recall(l, recalled, 1, draft)
+			}
+		default:
+			if unicode.IsPrint(r) {
+				l.insert([]rune{r})
+			}
+		}
+
+		e.refresh(l)
+	}
+}
+
+// Keys read from escape sequences.
+const (
+	keyUnknown = iota
+	keyUp
+	keyDown
+	keyRight
+	keyLeft
+	keyHome
+	keyEnd
+	keyDelete
+)
+
+// readEscape reads the rest of an escape sequence, after the ESC, and
+// returns the key it stands for. Sequences for other keys are consumed
+// and yield keyUnknown.
+func (e *editor) readEscape() (int, error) {
+	r, _, err := e.in.ReadRune()
+	if err != nil {
+		return keyUnknown, err
+	}
+	if r != '[' && r != 'O' {
+		return keyUnknown, nil
+	}
+
+	// parameters, such as the 3 in ESC [ 3 ~, precede the final byte
+	var params strings.Builder
+	for {
+		r, _, err = e.in.ReadRune()
+		if err != nil {
+			return keyUnknown, err
+		}
+		if r < '0' || r > '?' {
+			break
+		}
+		params.WriteRune(r)
+	}
+
+	switch r {
+	case 'A':
+		return keyUp, nil
+	case 'B':
+		return keyDown, nil
+	case 'C':
+		return keyRight, nil
+	case 'D':
+		return keyLeft, nil
+	case 'H':
+		return keyHome, nil
+	case 'F':
+		return keyEnd, nil
+	case '~':
+		switch params.String() {
+		case "1", "7":
+			return keyHome, nil
+		case "4", "8":
+			return keyEnd, nil
+		case "3":
+			return keyDelete, nil
+		}
+	}
+	return keyUnknown, nil
+}
+
+// recall replaces the line with the history entry step entries away from
+// the one recalled. Moving past the newest entry brings back the draft the
+// user was typing before browsing the history.
+func (e *editor) recall(l *line, recalled, step int, draft []rune) (int, []rune) {
+	entries := e.history.entries
+	next := recalled + step
+	if next < 0 || next > len(entries) {
+		return recalled, draft
+	}
+
+	if recalled == len(entries) {
+		draft = l.buf
+	}
+	if next == len(entries) {
+		l.buf = draft
+	} else {
+		l.buf = []rune(entries[next])
+	}
+	l.pos = len(l.buf)
+	return next, draft
+}
+
+// completeWord completes the word before the cursor. A single match is
+// inserted; several matches are extended to their longest common prefix,
+// or listed when that adds nothing.
+func (e *editor) completeWord(l *line) {
+	start := l.wordStart()
+	prefix := string(l.buf[start:l.pos])
+	if prefix == "" || e.complete == nil {
+		return
+	}
+
+	matches := e.complete(prefix)
+	switch len(matches) {
+	case 0:
+		fmt.Fprint(e.out, "\a")
+		return
+	case 1:
+		l.insert([]rune(strings.TrimPrefix(matches[0], prefix)))
+		return
+	}
+
+	common := matches[0]
+	for _, m := range matches[1:] {
+		for !strings.HasPrefix(m, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	if len(common) > len(prefix) {
+		l.insert([]rune(strings.TrimPrefix(common, prefix)))
+		return
+	}
+
+	fmt.Fprintf(e.out, "\n%s\n", strings.Join(matches, "  "))
+	fmt.Fprint(e.out, l.prompt)
+}
+
+// refresh redraws the line and puts the cursor in place.
+func (e *editor) refresh(l *line) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", l.prompt, string(l.buf))
+	if back := len(l.buf) - l.pos; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+// insert inserts text at the cursor and moves the cursor past it.
+func (l *line) insert(text []rune) {
+	buf := make([]rune, 0, len(l.buf)+len(text))
+	buf = append(buf, l.buf[:l.pos]...)
+	buf = append(buf, text...)
+	l.buf = append(buf, l.buf[l.pos:]...)
+	l.pos += len(text)
+}
+
+// delete removes the characters in [from, to), clamped to the line, and
+// leaves the cursor at from.
+func (l *line) delete(from, to int) {
+	if to > len(l.buf) {
+		to = len(l.buf)
+	}
+	if from >= to {
+		return
+	}
+	buf := make([]rune, 0, len(l.buf)-(to-from))
+	buf = append(buf, l.buf[:from]...)
+	l.buf = append(buf, l.buf[to:]...)
+	l.pos = from
+}
+
+// move moves the cursor by n characters, staying within the line.
+func (l *line) move(n int) {
+	l.pos += n
+	if l.pos < 0 {
+		l.pos = 0
+	}
+	if l.pos > len(l.buf) {
+		l.pos = len(l.buf)
+	}
+}
+
+// wordStart returns the position where the identifier ending at the
+// cursor starts.
+func (l *line) wordStart() int {
+	start := l.pos
+	for start > 0 && isWordChar(l.buf[start-1]) {
+		start--
+	}
+	return start
+}
+
+func isWordChar(ch rune) bool {
+	return ch == '_' || unicode.IsLetter(ch) || unicode.IsDigit(ch)
+}
@@ -1,31 +1,331 @@
+// Package repl implements Karma's interactive read-eval-print loop.
+//
+// Every line read is parsed and evaluated in one environment that lives
+// for the whole session, so bindings made on one line can be used on the
+// next. All output, including prompts and errors, goes to the writer the
+// loop is started with.
 package repl
 
 import (
-	"io"
 	"bufio"
 	"fmt"
-	"karma/token"
+	"io"
+	"karma/ast"
+	"karma/builtins"
+	"karma/diagnostic"
+	"karma/evaluator"
 	"karma/lexer"
+	"karma/object"
+	"karma/parser"
+	"karma/token"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
 )
 
 const PROMPT = ">> "
 
-func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
+// CONTINUATION_PROMPT is shown while an input spanning several lines is
+// still incomplete.
+const CONTINUATION_PROMPT = ".. "
+
+// Dump modes select what the REPL prints for an input instead of its value.
+const (
+	modeEval   = ""
+	modeTokens = "tokens"
+	modeAST    = "ast"
+)
+
+// session holds the state the REPL keeps between inputs.
+type session struct {
+	out  io.Writer
+	env  *object.Environment
+	mode string
+
+	// history holds every input that ran without errors, for :save.
+	history []string
+
+	// exit is set once an input calls the exit builtin, ending the session.
+	exit *object.Exit
+}
+
+// Start reads lines from in until it is exhausted, and writes prompts,
+// results and errors to out.
+//
+// An input with unclosed brackets or an unterminated string continues on
+// the next line, behind the CONTINUATION_PROMPT, and is only run once it is
+// complete. An interrupt (Ctrl-C) abandons the input being entered; while
+// an input runs, it ends the process, so a loop that never ends can still
+// be stopped.
+//
+// When in and out are a terminal, lines can be edited, earlier lines are
+// recalled with the arrow keys, from this and previous sessions, and Tab
+// completes keywords and the names bound in the session.
+//
+// Lines starting with a colon are commands; :help lists them.
+//
+// Start returns the status passed to the exit builtin, which ends the
+// session, or 0 when the input is exhausted.
+func Start(in io.Reader, out io.Writer) int {
+	if e := newTerminalEditor(in, out); e != nil {
+		s := newSession(out)
+		e.complete = s.complete
+		return s.loop(e)
+	}
+	return run(in, out, listenForInterrupts)
+}
+
+// listenForInterrupts delivers the interrupts the process receives on the
+// returned channel until stop is called. Outside of that, an interrupt
+// ends the process.
+func listenForInterrupts() (<-chan os.Signal, func()) {
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	return interrupts, func() { signal.Stop(interrupts) }
+}
+
+// newTerminalEditor returns an editor reading from in, or nil when in and
+// out are not both a terminal.
+func newTerminalEditor(in io.Reader, out io.Writer) *editor {
+	inFile, ok := in.(*os.File)
+	if !ok || !isTerminal(inFile) {
+		return nil
+	}
+	if outFile, ok := out.(*os.File); !ok || !isTerminal(outFile) {
+		return nil
+	}
+
+	return &editor{
+		in:      bufio.NewReader(inFile),
+		out:     out,
+		history: loadHistory(defaultHistoryPath()),
+		raw:     func() (func(), error) { return makeRaw(inFile) },
+	}
+}
+
+// run is the loop behind Start for input that is not a terminal. It calls
+// listen while waiting for each line, and an interrupt received on the
+// channel listen returns abandons the current input.
+func run(in io.Reader, out io.Writer, listen func() (<-chan os.Signal, func())) int {
+	r := &scanReader{out: out, lines: readLines(in), listen: listen}
+	return newSession(out).loop(r)
+}
+
+// newSession returns a session writing to out. The output of the print
+// builtin goes to out too.
+func newSession(out io.Writer) *session {
+	env := object.NewConfiguredEnvironment(&object.Config{Output: out})
+	return &session{out: out, env: env}
+}
 
+// loop reads and runs inputs from r until it is exhausted or an input
+// calls exit, and returns the exit status.
+func (s *session) loop(r lineReader) int {
+	var pending []string
 	for {
-		fmt.Printf(PROMPT)
-		
-		scanned := scanner.Scan()
-		if !scanned {
-			return
+		prompt := PROMPT
+		if len(pending) > 0 {
+			prompt = CONTINUATION_PROMPT
 		}
 
-		line := scanner.Text()
-		l := lexer.New(line)
+		line, err := r.ReadLine(prompt)
+		if err == errInterrupted {
+			fmt.Fprintln(s.out)
+			pending = nil
+			continue
+		}
+		if err != nil {
+			fmt.Fprintln(s.out)
+			return 0
+		}
 
-		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
-			fmt.Printf("%+v\n", tok)
+		pending = append(pending, line)
+		input := strings.Join(pending, "\n")
+		if !strings.HasPrefix(strings.TrimSpace(input), ":") && !isComplete(input) {
+			continue
 		}
+
+		pending = nil
+		s.handle(input)
+		if s.exit != nil {
+			return s.exit.Code
+		}
+	}
+}
+
+// readLines sends every line of in on the returned channel, which is
+// closed once in is exhausted.
+func readLines(in io.Reader) <-chan string {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	return lines
+}
+
+// isComplete reports whether input can be run as it is, rather than being
+// continued on the next line: every (, [, { and string it opens is closed.
+// Surplus closing brackets count as complete, so the parser can report them.
+func isComplete(input string) bool {
+	l := lexer.New("", input)
+
+	depth := 0
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			depth--
+		}
+	}
+
+	for _, err := range l.Errors() {
+		if err.Unterminated {
+			return false
+		}
+	}
+	return depth <= 0
+}
+
+// complete returns the keywords, the builtins and the names bound in the
+// session that start with prefix, in sorted order.
+func (s *session) complete(prefix string) []string {
+	var matches []string
+	seen := map[string]bool{}
+	names := append(token.Keywords(), builtins.Names()...)
+	for _, name := range append(names, s.env.Names()...) {
+		if strings.HasPrefix(name, prefix) && !seen[name] {
+			seen[name] = true
+			matches = append(matches, name)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// handle runs one complete input.
+func (s *session) handle(input string) {
+	if strings.HasPrefix(strings.TrimSpace(input), ":") {
+		s.command(strings.TrimSpace(input))
+		return
+	}
+	if strings.TrimSpace(input) == "" {
+		return
+	}
+
+	switch s.mode {
+	case modeTokens:
+		s.printTokens(input)
+	case modeAST:
+		s.printAST(input)
+	default:
+		s.eval(input)
+	}
+}
+
+// eval parses and evaluates input in the session environment and prints
+// the resulting value, if any.
+func (s *session) eval(input string) {
+	evaluated, ok := s.evalInput("", input)
+	if ok && evaluated != nil {
+		fmt.Fprintln(s.out, evaluated.Inspect())
+	}
+}
+
+// evalInput evaluates input like evalSource and adds it to the history,
+// for :save, when it ran without errors.
+func (s *session) evalInput(filename, input string) (object.Object, bool) {
+	evaluated, ok := s.evalSource(filename, input)
+	s.record(input, evaluated, ok)
+	return evaluated, ok
+}
+
+// record adds input, which evalSource returned evaluated and ok for, to
+// the history when it ran without errors.
+func (s *session) record(input string, evaluated object.Object, ok bool) {
+	if _, failed := evaluated.(*object.Error); ok && !failed && s.exit == nil {
+		s.history = append(s.history, input)
+	}
+}
+
+// bindings returns the values bound in the session environment, so that
+// rebound can tell whether an input changed any of them.
+func (s *session) bindings() map[string]object.Object {
+	values := make(map[string]object.Object)
+	for _, name := range s.env.Names() {
+		values[name], _ = s.env.Get(name)
+	}
+	return values
+}
+
+// rebound reports whether a name has been bound, or bound to another
+// value, since bindings returned before.
+func (s *session) rebound(before map[string]object.Object) bool {
+	after := s.bindings()
+	if len(after) != len(before) {
+		return true
+	}
+	for name, val := range after {
+		if before[name] != val {
+			return true
+		}
+	}
+	return false
+}
+
+// evalSource parses and evaluates input, read from the named file, in the
+// session environment, and returns its value. Syntax errors are printed.
+// A call to exit ends the session and yields no value. It reports whether
+// the input parsed.
+func (s *session) evalSource(filename, input string) (object.Object, bool) {
+	program, ok := s.parse(filename, input)
+	if !ok {
+		return nil, false
+	}
+
+	evaluated := evaluator.Eval(program, s.env)
+	if exit, ok := evaluated.(*object.Exit); ok {
+		s.exit = exit
+		return nil, true
+	}
+	return evaluated, true
+}
+
+// parse parses input, printing any syntax errors along with the lines
+// they are on. It reports whether the input was free of errors.
+func (s *session) parse(filename, input string) (*ast.Program, bool) {
+	l := lexer.New(filename, input)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		diagnostic.NewRenderer(s.out).RenderAll(p.Diagnostics(), input)
+		return nil, false
+	}
+	return program, true
+}
+
+func (s *session) printTokens(input string) {
+	l := lexer.New("", input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		fmt.Fprintf(s.out, "%-6s %-10s %q\n", tok.Start, tok.Type, tok.Literal)
+	}
+	for _, err := range l.Errors() {
+		fmt.Fprintf(s.out, "\t%s\n", err)
+	}
+}
+
+func (s *session) printAST(input string) {
+	program, ok := s.parse("", input)
+	if !ok {
+		return
+	}
+	for _, stmt := range program.Statements {
+		fmt.Fprintf(s.out, "%T %s\n", stmt, stmt.String())
 	}
 }
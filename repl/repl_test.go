@@ -0,0 +1,444 @@
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"karma/ast"
+	"karma/evaluator"
+	"karma/lexer"
+	"karma/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func runREPL(input string) string {
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+	return out.String()
+}
+
+func TestStartEvaluates(t *testing.T) {
+	input := `karma x = 5;
+x * 2
+karma add = fun(a, b) { a + b };
+add(x, 1)
+"x is ${x}"
+`
+	expected := ">> >> 10\n>> >> 6\n>> \"x is 5\"\n>> \n"
+
+	if got := runREPL(input); got != expected {
+		t.Errorf("wrong output.\nexpected=%q\n     got=%q", expected, got)
+	}
+}
+
+func TestStartReportsErrors(t *testing.T) {
+	input := `1 + 2)
+undefined
+karma ok = 1; ok
+`
+	expected := ">> 1:6: error[E0201]: expected an expression, found ')'\n" +
+		" 1 | 1 + 2)\n" +
+		"   |      ^\n" +
+		">> ERROR: identifier not found: undefined\n" +
+		">> 1\n" +
+		">> \n"
+
+	if got := runREPL(input); got != expected {
+		t.Errorf("wrong output.\nexpected=%q\n     got=%q", expected, got)
+	}
+}
+
+func TestDumpCommands(t *testing.T) {
+	input := `:tokens karma x = 1;
+:ast 1 + 2 * 3
+:ast
+karma y = x;
+:eval
+:bogus
+`
+	expected := ">> " +
+		"1:1    KARMA      \"karma\"\n" +
+		"1:7    IDENT      \"x\"\n" +
+		"1:9    =          \"=\"\n" +
+		"1:11   INT        \"1\"\n" +
+		"1:12   ;          \";\"\n" +
+		">> *ast.ExpressionStatement (1 + (2 * 3))\n" +
+		">> >> *ast.LetStatement karma y = x;\n" +
+		">> >> unknown command :bogus; type :help for a list\n" +
+		">> \n"
+
+	if got := runREPL(input); got != expected {
+		t.Errorf("wrong output.\nexpected=%q\n     got=%q", expected, got)
+	}
+}
+
+func TestMultiLineInput(t *testing.T) {
+	input := `karma add = fun(a, b) {
+  a + b
+};
+add(
+  1,
+  2)
+"first line
+second line"
+karma s = ` + "`raw ${" + `
+still raw` + "`" + `
+"sum ${1 +
+2}"
+`
+	expected := ">> .. .. >> .. .. 3\n" +
+		">> .. \"first line\\nsecond line\"\n" +
+		">> .. >> .. \"sum 3\"\n>> \n"
+
+	if got := runREPL(input); got != expected {
+		t.Errorf("wrong output.\nexpected=%q\n     got=%q", expected, got)
+	}
+}
+
+func TestIsComplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 + 2", true},
+		{"fun(x) {", false},
+		{"fun(x) { x }", true},
+		{"add(1,", false},
+		{"[1, 2,", false},
+		{"xs[0]", true},
+		{"}", true},
+		{`"open`, false},
+		{`"a ${b`, false},
+		{"`raw", false},
+		{"1 /* comment", false},
+		{"1 // comment (", true},
+		{`"(" + "{"`, true},
+	}
+
+	for _, tt := range tests {
+		if got := isComplete(tt.input); got != tt.expected {
+			t.Errorf("isComplete(%q) wrong. expected=%t, got=%t", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// syncBuffer is a bytes.Buffer that can be written by the REPL and read by
+// a test at the same time.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// waitFor blocks until the output ends with suffix.
+func (b *syncBuffer) waitFor(t *testing.T, suffix string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.HasSuffix(b.String(), suffix) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %q. output=%q", suffix, b.String())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestInterruptAbandonsInput(t *testing.T) {
+	in, w := io.Pipe()
+	out := &syncBuffer{}
+	interrupts := make(chan os.Signal)
+	done := make(chan struct{})
+	listen := func() (<-chan os.Signal, func()) { return interrupts, func() {} }
+
+	go func() {
+		run(in, out, listen)
+		close(done)
+	}()
+
+	out.waitFor(t, ">> ")
+	io.WriteString(w, "karma f = fun(x) {\n")
+	out.waitFor(t, ".. ")
+	interrupts <- os.Interrupt
+	out.waitFor(t, "\n>> ")
+	io.WriteString(w, "1 + 1\n")
+	out.waitFor(t, "2\n>> ")
+	w.Close()
+	<-done
+
+	expected := ">> .. \n>> 2\n>> \n"
+	if got := out.String(); got != expected {
+		t.Errorf("wrong output.\nexpected=%q\n     got=%q", expected, got)
+	}
+}
+
+func TestInterruptsOnlyWhileReading(t *testing.T) {
+	lines := make(chan string, 1)
+	lines <- "1 + 1"
+	listening := false
+	r := &scanReader{out: io.Discard, lines: lines, listen: func() (<-chan os.Signal, func()) {
+		listening = true
+		return nil, func() { listening = false }
+	}}
+
+	if line, err := r.ReadLine(PROMPT); line != "1 + 1" || err != nil {
+		t.Fatalf("wrong line. got=%q, %v", line, err)
+	}
+	if listening {
+		t.Errorf("still listening for interrupts after the line was read")
+	}
+}
+
+func TestSessionCommands(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.ka")
+	if err := ioutil.WriteFile(lib, []byte("#!/usr/bin/env karma\nkarma double = fun(x) { x * 2 };\ndouble(21)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	saved := filepath.Join(dir, "session")
+
+	input := `karma z = 1 // note
+:load ` + lib + `
+karma x = double(5)
+nope
+:type x
+:type "s" + "t"
+:type fun() {}
+:env
+:save ` + saved + `
+:reset
+:env
+:load ` + filepath.Join(dir, "missing.ka") + `
+:type
+`
+	expected := ">> >> 42\n" +
+		">> >> ERROR: identifier not found: nope\n" +
+		">> INTEGER\n" +
+		">> STRING\n" +
+		">> FUNCTION\n" +
+		">> double = fun(x) { (x * 2) }\nx = 10\nz = 1\n" +
+		">> saved 3 inputs to " + saved + ".karma\n" +
+		">> session reset\n" +
+		">> no bindings\n" +
+		">> cannot load: open " + filepath.Join(dir, "missing.ka") + ": no such file or directory\n" +
+		">> usage: :type <expr>\n" +
+		">> \n"
+
+	if got := runREPL(input); got != expected {
+		t.Errorf("wrong output.\nexpected=%q\n     got=%q", expected, got)
+	}
+
+	script, err := ioutil.ReadFile(saved + ".karma")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// :type inputs are not saved, and a semicolon after a comment would
+	// be commented out
+	expectedScript := "karma z = 1 // note\n;\n" +
+		"karma double = fun(x) { x * 2 };\ndouble(21)\n;\n" +
+		"karma x = double(5)\n;\n"
+	if string(script) != expectedScript {
+		t.Errorf("wrong saved script.\nexpected=%q\n     got=%q", expectedScript, string(script))
+	}
+
+	// the saved script recreates the session
+	if got := runREPL(":load " + saved + ".karma\n:env\n"); !strings.Contains(got, "x = 10\nz = 1\n") {
+		t.Errorf("loading the saved script did not restore x. output=%q", got)
+	}
+}
+
+func TestTimeCommand(t *testing.T) {
+	got := runREPL(":time 2 * 21\n")
+
+	if !strings.HasPrefix(got, ">> 42\ntook ") || !strings.HasSuffix(got, "\n>> \n") {
+		t.Errorf("wrong output for :time. got=%q", got)
+	}
+
+	s := newSession(ioutil.Discard)
+	s.command(":time 1 + 1")
+	if len(s.history) != 0 {
+		t.Errorf(":time added to the history. got=%q", s.history)
+	}
+}
+
+func TestCommandsThatRebind(t *testing.T) {
+	s := newSession(ioutil.Discard)
+	for _, input := range []string{"karma x = 1", ":type x = 5", ":time x += 1", ":type x * 2", ":time karma y = x"} {
+		s.handle(input)
+	}
+
+	expected := []string{"karma x = 1", "x = 5", "x += 1", "karma y = x"}
+	if strings.Join(s.history, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong history.\nexpected=%q\n     got=%q", expected, s.history)
+	}
+}
+
+func TestHelpCommand(t *testing.T) {
+	got := runREPL(":help\n")
+
+	for _, c := range commands {
+		if !strings.Contains(got, c.name) {
+			t.Errorf(":help does not mention %s. got=%q", c.name, got)
+		}
+	}
+}
+
+// editLines feeds keys to an editor, whose completions are the keywords
+// and the names x1, x2 and y, and returns the lines it reads.
+func editLines(t *testing.T, h *history, keys string) []string {
+	t.Helper()
+
+	s := newSession(ioutil.Discard)
+	evaluator.Eval(program(t, "karma x1 = 1; karma x2 = 2; karma y = 3;"), s.env)
+
+	e := &editor{
+		in:       bufio.NewReader(strings.NewReader(keys)),
+		out:      ioutil.Discard,
+		history:  h,
+		complete: s.complete,
+	}
+
+	var lines []string
+	for {
+		line, err := e.ReadLine(PROMPT)
+		if err == errInterrupted {
+			lines = append(lines, "^C")
+			continue
+		}
+		if err != nil {
+			return lines
+		}
+		lines = append(lines, line)
+	}
+}
+
+func program(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New("", input))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return prog
+}
+
+func TestEditor(t *testing.T) {
+	const (
+		up    = "\x1b[A"
+		down  = "\x1b[B"
+		right = "\x1b[C"
+		left  = "\x1b[D"
+		home  = "\x1b[H"
+		end   = "\x1b[F"
+		del   = "\x1b[3~"
+		bs    = "\x7f"
+	)
+
+	tests := []struct {
+		keys     string
+		expected []string
+	}{
+		{"1 + 2\r", []string{"1 + 2"}},
+		{"1 + 2\n", []string{"1 + 2"}},
+		{"13" + left + "2\r", []string{"123"}},
+		{"23" + home + "1" + end + "4\r", []string{"1234"}},
+		{"23\x01" + "1\x05" + "4\r", []string{"1234"}},
+		{"124" + bs + "3" + left + left + del + "\r", []string{"13"}},
+		{"ab\x02\x02\x06c\r", []string{"acb"}},
+		{"abc" + left + "\x0b\r", []string{"ab"}},
+		{"abc" + left + "\x15\r", []string{"c"}},
+		{"karma value  \x17\r", []string{"karma "}},
+		{"abc" + left + left + "\x04\r", []string{"ac"}},
+		{"1\r2\r" + up + up + "\r", []string{"1", "2", "1"}},
+		{"1\r2\r" + up + up + down + "\r", []string{"1", "2", "2"}},
+		{"1\r3" + up + down + "\r", []string{"1", "3"}},
+		{"1\r\x10\x10\x10\r", []string{"1", "1"}},
+		{"partial\x03next\r", []string{"^C", "next"}},
+		{"ret\t 1\r", []string{"return 1"}},
+		{"pri\t(1)\r", []string{"print(1)"}},
+		{"y\t\r", []string{"y"}},
+		{"x\t\r", []string{"x"}},
+		{"x\t2 + y\r", []string{"x2 + y"}},
+		{"zzz\t\r", []string{"zzz"}},
+		{"f\t\r", []string{"f"}},
+		{"fu\t\r", []string{"fun"}},
+		{right + right + "\x1bOH\x1b[1;5C" + "ok\r", []string{"ok"}},
+		{"\x04", nil},
+	}
+
+	for _, tt := range tests {
+		got := editLines(t, &history{}, tt.keys)
+		if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("keys %q: wrong lines. want=%q, got=%q", tt.keys, tt.expected, got)
+		}
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), historyFile)
+
+	editLines(t, loadHistory(path), "karma a = 1\r\r1\r1\r")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "karma a = 1\n1\n"; string(data) != expected {
+		t.Errorf("wrong history file. want=%q, got=%q", expected, string(data))
+	}
+
+	// a new session recalls the lines of the previous one
+	got := editLines(t, loadHistory(path), "\x1b[A\x1b[A\r")
+	if len(got) != 1 || got[0] != "karma a = 1" {
+		t.Errorf("history was not recalled. got=%q", got)
+	}
+}
+
+func TestSessionComplete(t *testing.T) {
+	s := newSession(ioutil.Discard)
+	evaluator.Eval(program(t, "karma fact = 1; karma x = 2;"), s.env)
+
+	tests := []struct {
+		prefix   string
+		expected string
+	}{
+		{"f", "fact,false,first,for,fun"},
+		{"fa", "fact,false"},
+		{"x", "x"},
+		{"le", "len"},
+		{"karma", "karma"},
+		{"q", ""},
+	}
+
+	for _, tt := range tests {
+		if got := strings.Join(s.complete(tt.prefix), ","); got != tt.expected {
+			t.Errorf("complete(%q) wrong. want=%q, got=%q", tt.prefix, tt.expected, got)
+		}
+	}
+}
+
+func TestExitEndsSession(t *testing.T) {
+	var out bytes.Buffer
+	code := Start(strings.NewReader("1\nexit(3)\n2\n"), &out)
+
+	if code != 3 {
+		t.Errorf("wrong exit status. want=3, got=%d", code)
+	}
+	if expected := ">> 1\n>> "; out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\n     got=%q", expected, out.String())
+	}
+}
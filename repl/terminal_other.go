@@ -0,0 +1,19 @@
+//go:build !linux
+// +build !linux
+
+package repl
+
+import (
+	"errors"
+	"os"
+)
+
+// isTerminal reports whether f is connected to a terminal. Line editing is
+// only supported on Linux, so elsewhere the REPL always reads plain lines.
+func isTerminal(f *os.File) bool {
+	return false
+}
+
+func makeRaw(f *os.File) (restore func(), err error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
@@ -1,72 +1,224 @@
 package token
 
+import (
+	"fmt"
+	"sort"
+)
+
 // TokenType is the category of a token (identifier, keyword, operator, ..)
 type TokenType string
 
-// Token represents a lexical token with its type and literal value
+// Token represents a lexical token with its type, literal value and the
+// span of source text it was read from.
 type Token struct {
 	// Type is the category of the token.
 	Type TokenType
-	// Literal is the exact text from the source code.
+	// Literal is the exact text from the source code. For string tokens
+	// it is the text with quotes removed and escape sequences decoded.
 	Literal string
+	// Start is the position of the first character of the token.
+	Start Position
+	// End is the position just past the last character of the token.
+	End Position
+}
+
+// CommentKind distinguishes the forms of comment Karma supports.
+type CommentKind int
+
+const (
+	LineComment  CommentKind = iota // from // to the end of the line
+	BlockComment                    // between /* and */, may be nested
+	DocComment                      // from /// to the end of the line
+)
+
+// Comment is a piece of trivia: source text the parser ignores but that
+// tools such as formatters and documentation generators need to keep.
+type Comment struct {
+	Kind CommentKind
+	// Text is the comment as written, including its // or /* */ markers.
+	Text  string
+	Start Position
+	End   Position
+}
+
+// Position describes a location in a source file.
+type Position struct {
+	Filename string // file name, may be empty
+	Offset   int    // byte offset, starting at 0
+	Line     int    // line number, starting at 1
+	Column   int    // column number, starting at 1
+}
+
+// IsValid reports whether the position carries a line number.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns the position as "file:line:column", or "line:column"
+// when the file name is empty. An invalid position is rendered as "-"
+// (optionally prefixed by the file name).
+func (p Position) String() string {
+	s := p.Filename
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
 }
 
 // keywords maps language keywords to their TokenType.
-var keywords = map[string]TokenType {
-	"fun": FUNCTION,
-	"karma": KARMA,
-	"true": TRUE,
-	"false": FALSE,
-	"if": IF,
-	"else": ELSE,
-	"return": RETURN,
+var keywords = map[string]TokenType{
+	"fun":      FUNCTION,
+	"karma":    KARMA,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
 }
 
 // Special tokens
 const (
 	ILLEGAL = "ILLEGAL" // unknown token/character
-	EOF = "EOF"         // end of file
+	EOF     = "EOF"     // end of file
 
 	// Identifiers + literals
-	IDENT = "IDENT"
-	INT = "INT"
+	IDENT  = "IDENT"
+	INT    = "INT"
+	FLOAT  = "FLOAT"
+	STRING = "STRING"
+
+	// Pieces of an interpolated string such as "a ${x} b ${y} c":
+	// STRING_HEAD is "a ", STRING_MIDDLE is " b " and STRING_TAIL is " c".
+	// The tokens of the embedded expressions appear in between.
+	STRING_HEAD   = "STRING_HEAD"
+	STRING_MIDDLE = "STRING_MIDDLE"
+	STRING_TAIL   = "STRING_TAIL"
 
 	// Operators
-	ASSIGN = "="
-	PLUS = "+"
-	MINUS = "-"
+	ASSIGN   = "="
+	PLUS     = "+"
+	MINUS    = "-"
 	ASTERISK = "*"
-	SLASH = "/"
-	BANG = "!"
+	SLASH    = "/"
+	BANG     = "!"
 
-	LT = "<"
-	GT = ">"
-	EQ = "=="
+	PERCENT = "%"
+
+	LT     = "<"
+	GT     = ">"
+	LT_EQ  = "<="
+	GT_EQ  = ">="
+	EQ     = "=="
 	NOT_EQ = "!="
 
+	AND = "&&"
+	OR  = "||"
+
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+
+	ARROW     = "->"
+	FAT_ARROW = "=>"
+	DOT_DOT   = ".."
+	PIPE      = "|>"
+
 	// Delimiters
-	COMMA = ","
+	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
 
-	LPAREN = "("
-	RPAREN = ")"
-	LBRACE = "{"
-	RBRACE = "}"
+	LPAREN   = "("
+	RPAREN   = ")"
+	LBRACE   = "{"
+	RBRACE   = "}"
+	LBRACKET = "["
+	RBRACKET = "]"
 
 	// keywords
 	FUNCTION = "FUNCTION"
-	KARMA = "KARMA"
-	TRUE = "TRUE"
-	FALSE = "FALSE"
-	IF = "IF"
-	ELSE = "ELSE"
-	RETURN = "RETURN"
+	KARMA    = "KARMA"
+	TRUE     = "TRUE"
+	FALSE    = "FALSE"
+	IF       = "IF"
+	ELSE     = "ELSE"
+	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
 )
 
+// Keywords returns every language keyword, in sorted order.
+func Keywords() []string {
+	names := make([]string, 0, len(keywords))
+	for name := range keywords {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // LookupIdent checks if an identifier is a keyword, returning the proper TokenType.
 func LookupIdent(ident string) TokenType {
 	if tok, ok := keywords[ident]; ok {
 		return tok
 	}
 	return IDENT
-}
\ No newline at end of file
+}
+
+// descriptions names the token types whose type says little to someone
+// reading an error message.
+var descriptions = map[TokenType]string{
+	ILLEGAL:       "an invalid character",
+	EOF:           "the end of input",
+	IDENT:         "a name",
+	INT:           "a number",
+	FLOAT:         "a number",
+	STRING:        "a string",
+	STRING_HEAD:   "a string",
+	STRING_MIDDLE: "the rest of a string",
+	STRING_TAIL:   "the end of a string",
+}
+
+// Describe returns a description of t for error messages, in plain words
+// rather than token type names: "a name" for IDENT, "the keyword 'fun'"
+// for FUNCTION and "'('" for LPAREN.
+func (t TokenType) Describe() string {
+	if desc, ok := descriptions[t]; ok {
+		return desc
+	}
+	for word, keyword := range keywords {
+		if keyword == t {
+			return fmt.Sprintf("the keyword '%s'", word)
+		}
+	}
+	return fmt.Sprintf("'%s'", string(t))
+}
+
+// Describe returns a description of tok for error messages, including its
+// text where that helps: "the name 'x'", "the number 42" or "'('".
+func (tok Token) Describe() string {
+	switch tok.Type {
+	case IDENT:
+		return fmt.Sprintf("the name '%s'", tok.Literal)
+	case INT, FLOAT:
+		return fmt.Sprintf("the number %s", tok.Literal)
+	case ILLEGAL:
+		return fmt.Sprintf("the invalid character %q", tok.Literal)
+	}
+	return tok.Type.Describe()
+}